@@ -0,0 +1,380 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Operators of the quals pushed down as filter clauses
+var (
+	equalsOperators    = []string{"="}
+	timestampOperators = []string{">", ">=", "=", "<", "<="}
+	usnOperators       = []string{">", ">=", "=", "<", "<="}
+	boolOperators      = []string{"<>", "="}
+)
+
+// A columnValue converts the attributes backing a column to its value
+type columnValue func(ctx context.Context, entry *ldap.Entry, attributes []string, profile *directoryProfile) interface{}
+
+// A mappedColumn declares a column of a mappedTable: its schema, the attributes backing it, how their values
+// are converted to the value of the column and which quals on it are pushed down
+type mappedColumn struct {
+	Name        string
+	Description string
+	Type        proto.ColumnType
+	Sort        plugin.SortOrder
+	// Transform of columns which are not read from the row, e.g. scope
+	Transform *transform.ColumnTransforms
+	// Attribute backing the column unless the profile or column_attributes map the column to another one.
+	// Defaults to the lower camel case form of the column name, e.g. pwd_min_age -> pwdMinAge
+	Attribute string
+	// Attributes backing the column, when not the attribute the profile maps the column to.
+	// Columns derived from the DN are backed by none
+	Attributes func(profile *directoryProfile, table string) []string
+	// Converts the attributes backing the column. Defaults to the value of the first attribute
+	Value columnValue
+	// Operators of the quals on the column which are pushed down as filter clauses, if any
+	Operators []string
+	// Whether the column identifies a single entry, so that the table can be got by it as well as by dn
+	Get bool
+}
+
+// A mappedTable is a table whose rows are built from entries according to the declarations of its columns,
+// so that the row, the attributes requested and the quals pushed down all agree on the attribute backing each column
+type mappedTable struct {
+	Name    string
+	Columns []*mappedColumn
+	// Filter matching the objects of the table, set in the connection config or else defined by the profile
+	ObjectFilter func(ldapConfig ldapConfig, profile *directoryProfile) string
+	// Operational attributes requested along with all user attributes when the attributes column is selected
+	OperationalAttributes []string
+}
+
+// columns returns the schema of the table
+func (t *mappedTable) columns() []*plugin.Column {
+	var columns []*plugin.Column
+	for _, c := range t.Columns {
+		column := &plugin.Column{
+			Name:        c.Name,
+			Description: c.Description,
+			Type:        c.Type,
+			Sort:        c.Sort,
+			Transform:   c.Transform,
+		}
+		if column.Transform == nil {
+			column.Transform = transform.FromField(c.Name)
+		}
+		columns = append(columns, column)
+	}
+	return commonColumns(columns)
+}
+
+// keyColumns returns the optional key columns of the list hydrate, i.e. the columns whose quals are pushed down
+func (t *mappedTable) keyColumns() []*plugin.KeyColumn {
+	var keyColumns []*plugin.KeyColumn
+	for _, c := range t.Columns {
+		if len(c.Operators) > 0 {
+			keyColumns = append(keyColumns, &plugin.KeyColumn{Name: c.Name, Operators: c.Operators, Require: plugin.Optional})
+		}
+		// Tables searched with a scope are searched one level under the DN of a parent_dn qual
+		if c.Name == "scope" {
+			keyColumns = append(keyColumns, &plugin.KeyColumn{Name: "parent_dn", Require: plugin.Optional})
+		}
+	}
+	return keyColumns
+}
+
+// getKeyColumns returns the key columns of the get hydrate, i.e. dn and the columns identifying a single entry
+func (t *mappedTable) getKeyColumns() []*plugin.KeyColumn {
+	columns := []string{"dn"}
+	for _, c := range t.Columns {
+		if c.Get {
+			columns = append(columns, c.Name)
+		}
+	}
+	return plugin.AnyColumn(columns)
+}
+
+// getProfile returns the directory profile of the connection, with the default attributes of the columns of the table
+func (t *mappedTable) getProfile(ctx context.Context, d *plugin.QueryData) (*directoryProfile, error) {
+	profile, err := getDirectoryProfile(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	return t.withDefaultAttributes(profile), nil
+}
+
+// withDefaultAttributes returns a copy of the profile mapping the columns of the table to their default attribute,
+// unless the profile or column_attributes already map them, so that quals, ORDER BY and rows all agree on it
+func (t *mappedTable) withDefaultAttributes(profile *directoryProfile) *directoryProfile {
+	defaults := map[string]string{}
+	for _, c := range t.Columns {
+		if c.Attribute == "" {
+			continue
+		}
+		if _, ok := profile.TableColumnAttributes[t.Name][c.Name]; ok {
+			continue
+		}
+		if _, ok := profile.ColumnAttributes[c.Name]; ok {
+			continue
+		}
+		defaults[c.Name] = c.Attribute
+	}
+	if len(defaults) == 0 {
+		return profile
+	}
+
+	copied := *profile
+	copied.TableColumnAttributes = map[string]map[string]string{t.Name: defaults}
+	for table, tableColumnAttributes := range profile.TableColumnAttributes {
+		if table != t.Name {
+			copied.TableColumnAttributes[table] = tableColumnAttributes
+			continue
+		}
+		for column, attribute := range tableColumnAttributes {
+			defaults[column] = attribute
+		}
+	}
+	return &copied
+}
+
+// attributes returns the attributes backing a column
+func (t *mappedTable) attributes(profile *directoryProfile, c *mappedColumn) []string {
+	if c.Attributes != nil {
+		return c.Attributes(profile, t.Name)
+	}
+	return []string{profile.attributeName(t.Name, c.Name)}
+}
+
+// requestAttributes returns the attributes set in the connection config, or else those needed by the columns of the query
+func (t *mappedTable) requestAttributes(d *plugin.QueryData, profile *directoryProfile) []string {
+	ldapConfig := GetConfig(d.Connection)
+	if ldapConfig.Attributes != nil {
+		return ldapConfig.Attributes
+	}
+
+	columnAttributes := map[string][]string{}
+	for _, c := range t.Columns {
+		if c.Attributes != nil {
+			columnAttributes[c.Name] = c.Attributes(profile, t.Name)
+		}
+	}
+	return queryAttributes(d, profile, columnAttributes, t.OperationalAttributes...)
+}
+
+// buildRow converts an entry to a row keyed by column name
+func (t *mappedTable) buildRow(ctx context.Context, d *plugin.QueryData, entry *ldap.Entry, baseDN string, profile *directoryProfile) map[string]interface{} {
+	row := map[string]interface{}{
+		"dn":      entry.DN,
+		"base_dn": baseDN,
+	}
+	if d.EqualsQuals["filter"] != nil {
+		row["filter"] = d.EqualsQuals["filter"].GetStringValue()
+	}
+
+	for _, c := range t.Columns {
+		if containsEqualFold(nonAttributeColumns, c.Name) {
+			continue
+		}
+		value := c.Value
+		if value == nil {
+			value = stringValue
+		}
+		row[c.Name] = value(ctx, entry, t.attributes(profile, c), profile)
+	}
+
+	return row
+}
+
+// getRow returns the row of the object identified by the key quals of a get hydrate, provided it matches the
+// object filter of the table, e.g. so that a group is not returned as a user. An object is got by dn with a
+// base object search, or else searched under the base DNs of the table by the other columns identifying it
+func (t *mappedTable) getRow(ctx context.Context, d *plugin.QueryData, profile *directoryProfile) (interface{}, error) {
+	ldapConfig := GetConfig(d.Connection)
+	objectFilter := t.ObjectFilter(ldapConfig, profile)
+	attributes := t.requestAttributes(d, profile)
+
+	if d.EqualsQuals["dn"] != nil {
+		searchReq := ldap.NewSearchRequest(d.EqualsQualString("dn"), ldap.ScopeBaseObject, 0, 1, 0, false, objectFilter, attributes, []ldap.Control{})
+
+		result, err := search(ctx, d, searchReq)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Entries) == 0 {
+			return nil, nil
+		}
+		return t.buildRow(ctx, d, result.Entries[0], ldapConfig.baseDNOf(t.Name, result.Entries[0].DN), profile), nil
+	}
+
+	filter := t.getFilter(d, objectFilter, profile)
+	plugin.Logger(ctx).Debug("ldap_column_mapping.getRow", "table", t.Name, "filter", filter)
+
+	var row interface{}
+	matches := 0
+	err := searchBaseDNs(ctx, d, ldapConfig.baseDNs(t.Name), filter, attributes, profile, func(entry *ldap.Entry, baseDN string) {
+		matches++
+		if row == nil {
+			row = t.buildRow(ctx, d, entry, baseDN, profile)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Rather than returning an arbitrary one, fail when the value identifies several objects, e.g. the same
+	// sAMAccountName in two domains of a forest
+	if matches > 1 {
+		return nil, fmt.Errorf("%d objects of table %s match %s, get them by dn instead", matches, t.Name, filter)
+	}
+	return row, nil
+}
+
+// getFilter returns the filter matching the object identified by the key qual of a get hydrate other than dn.
+// The value is escaped, so that it is matched exactly rather than as a substring, e.g. for a* or (
+func (t *mappedTable) getFilter(d *plugin.QueryData, objectFilter string, profile *directoryProfile) string {
+	var clauses strings.Builder
+	for _, c := range t.Columns {
+		if !c.Get || d.EqualsQuals[c.Name] == nil {
+			continue
+		}
+		attribute := t.attributes(profile, c)[0]
+		clauses.WriteString(buildClause(attribute, filterValue(attribute, d.EqualsQualString(c.Name)), "="))
+	}
+	return "(&" + objectFilter + clauses.String() + ")"
+}
+
+// configuredFilter returns the object filter set in the connection config, if any, or else the default one
+func configuredFilter(configured *string, defaultFilter string) string {
+	if configured != nil && *configured != "" {
+		return *configured
+	}
+	return defaultFilter
+}
+
+// fixedAttributes backs a column by the given attributes, whatever the directory flavor
+func fixedAttributes(attributes ...string) func(*directoryProfile, string) []string {
+	return func(*directoryProfile, string) []string {
+		return attributes
+	}
+}
+
+// columnAttributes backs a column by the attribute backing another column of the table, e.g. title by cn
+func columnAttributes(column string) func(*directoryProfile, string) []string {
+	return func(profile *directoryProfile, table string) []string {
+		return []string{profile.attributeName(table, column)}
+	}
+}
+
+// noAttributes backs columns derived from the DN
+func noAttributes(*directoryProfile, string) []string {
+	return []string{}
+}
+
+// stringValue returns the value of the first attribute
+func stringValue(_ context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return ""
+	}
+	return entryAttributeValue(entry, attributes[0])
+}
+
+// valuesValue returns all the values of the first attribute
+func valuesValue(_ context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return []string{}
+	}
+	return entry.GetEqualFoldAttributeValues(attributes[0])
+}
+
+// intValue returns the value of the first attribute as an integer, or nil if it is missing or invalid
+func intValue(ctx context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+	return convertToInt(ctx, entry.GetEqualFoldAttributeValue(attributes[0]))
+}
+
+// boolValue returns the value of the first attribute as a boolean, or nil if it is missing or invalid
+func boolValue(ctx context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+	return convertToBool(ctx, entry.GetEqualFoldAttributeValue(attributes[0]))
+}
+
+// intervalValue returns the value of the first attribute, an AD interval, as a number of seconds,
+// or nil if it is missing, invalid or means never
+func intervalValue(ctx context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+	return convertIntervalToSeconds(ctx, entry.GetEqualFoldAttributeValue(attributes[0]))
+}
+
+// daysValue returns the value of the first attribute, a number of days since 1970-01-01, as a timestamp,
+// or nil if it is missing or invalid
+func daysValue(ctx context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+	return convertDaysToTimestamp(ctx, entry.GetEqualFoldAttributeValue(attributes[0]))
+}
+
+// timestampValue returns the value of the first attribute as a timestamp, or nil if it is missing or invalid
+func timestampValue(ctx context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+	timestamp := convertToTimestamp(ctx, entry.GetEqualFoldAttributeValue(attributes[0]))
+	if timestamp.IsZero() {
+		return nil
+	}
+	return timestamp
+}
+
+// timestampsValue returns the valid values of the first attribute as timestamps
+func timestampsValue(ctx context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return nil
+	}
+	return convertToTimestamps(ctx, entry.GetEqualFoldAttributeValues(attributes[0]))
+}
+
+// objectSidValue returns the SID of an entry. AD stores it in binary form, whereas FreeIPA stores its string representation
+func objectSidValue(_ context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return ""
+	}
+	if attributes[0] == "objectSid" {
+		return getObjectSid(entry)
+	}
+	return entry.GetEqualFoldAttributeValue(attributes[0])
+}
+
+// objectGUIDValue returns the GUID of an entry. AD stores it in binary form, whereas OpenLDAP, 389 DS and FreeIPA
+// store the string representation of a UUID
+func objectGUIDValue(_ context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	if len(attributes) == 0 {
+		return ""
+	}
+	if attributes[0] == "objectGUID" {
+		return getObjectGUID(entry)
+	}
+	return entry.GetEqualFoldAttributeValue(attributes[0])
+}
+
+// organizationalUnitValue returns the organizational unit of an entry, derived from its DN
+func organizationalUnitValue(_ context.Context, entry *ldap.Entry, _ []string, _ *directoryProfile) interface{} {
+	return getOrganizationUnit(entry.DN)
+}
+
+// attributesValue returns all the attributes returned for an entry
+func attributesValue(ctx context.Context, entry *ldap.Entry, _ []string, _ *directoryProfile) interface{} {
+	return transformAttributes(ctx, entry.Attributes)
+}
@@ -0,0 +1,114 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+func TestMappedTableGetFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		equalsQuals plugin.KeyColumnEqualsQualMap
+		profile     *directoryProfile
+		want        string
+	}{
+		{
+			name:        "sam account name with a wildcard",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"sam_account_name": stringQualValue("a*")},
+			profile:     directoryProfiles[DirectoryTypeActiveDirectory],
+			want:        `(&(objectClass=user)(sAMAccountName=a\2a))`,
+		},
+		{
+			name:        "sam account name with a parenthesis",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"sam_account_name": stringQualValue("a(b")},
+			profile:     directoryProfiles[DirectoryTypeOpenLDAP],
+			want:        `(&(objectClass=user)(uid=a\28b))`,
+		},
+		{
+			name:        "object sid",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"object_sid": stringQualValue("S-1-5-21-1004336348-1177238915-682003330-512")},
+			profile:     directoryProfiles[DirectoryTypeActiveDirectory],
+			want:        `(&(objectClass=user)(objectSid=S-1-5-21-1004336348-1177238915-682003330-512))`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d := &plugin.QueryData{EqualsQuals: test.equalsQuals}
+
+			got := userTable.getFilter(d, "(objectClass=user)", test.profile)
+			if got != test.want {
+				t.Errorf("getFilter() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMappedTableBuildRow(t *testing.T) {
+	ctx := testContext()
+	d := &plugin.QueryData{EqualsQuals: plugin.KeyColumnEqualsQualMap{}}
+	entry := ldap.NewEntry("CN=Admins PSO,CN=Password Settings Container,CN=System,DC=example,DC=com", map[string][]string{
+		"cn":                              {"Admins PSO"},
+		"msDS-PasswordSettingsPrecedence": {"10"},
+		"msDS-MaximumPasswordAge":         {"-36288000000000"},
+		"msDS-LockoutDuration":            {"-9223372036854775808"},
+		"msDS-PasswordComplexityEnabled":  {"TRUE"},
+		"whenCreated":                     {"20210830112105.0Z"},
+		"description":                     {"Policy of the administrators"},
+	})
+
+	tests := []struct {
+		name             string
+		columnAttributes map[string]string
+		want             map[string]interface{}
+	}{
+		{
+			name: "default attributes",
+			want: map[string]interface{}{
+				"precedence":               int64(10),
+				"max_password_age_seconds": int64(3628800),
+				"lockout_duration_seconds": nil,
+				"complexity_enabled":       true,
+				"when_created":             time.Date(2021, 8, 30, 11, 21, 5, 0, time.UTC),
+				"title":                    "Admins PSO",
+			},
+		},
+		{
+			name:             "attribute set in column_attributes",
+			columnAttributes: map[string]string{"ldap_password_settings_object.precedence": "description"},
+			want: map[string]interface{}{
+				"precedence": nil,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			profile := directoryProfiles[DirectoryTypeActiveDirectory].withColumnAttributes(test.columnAttributes)
+			profile = passwordSettingsObjectTable.withDefaultAttributes(profile)
+
+			row := passwordSettingsObjectTable.buildRow(ctx, d, entry, "DC=example,DC=com", profile)
+			for column, want := range test.want {
+				if got := dereference(row[column]); !reflect.DeepEqual(got, want) {
+					t.Errorf("buildRow()[%s] = %v, want %v", column, got, want)
+				}
+			}
+		})
+	}
+}
+
+// dereference returns the value a pointer points to, or nil for a nil pointer
+func dereference(value interface{}) interface{} {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Ptr {
+		return value
+	}
+	if v.IsNil() {
+		return nil
+	}
+	return v.Elem().Interface()
+}
@@ -1,26 +1,126 @@
 package ldap
 
 import (
+	"fmt"
+
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
 )
 
 type ldapConfig struct {
-	Attributes                     []string `hcl:"attributes,optional"`
-	BaseDN                         *string  `hcl:"base_dn"`
-	Username                       *string  `hcl:"username"`
-	Password                       *string  `hcl:"password"`
-	Host                           *string  `hcl:"host"`
-	Port                           *string  `hcl:"port"`
-	TLSRequired                    *bool    `hcl:"tls_required"`
-	UserObjectFilter               *string  `hcl:"user_object_filter"`
-	GroupObjectFilter              *string  `hcl:"group_object_filter"`
-	OrganizationalUnitObjectFilter *string  `hcl:"ou_object_filter"`
+	Attributes                     []string            `hcl:"attributes,optional"`
+	BaseDN                         *string             `hcl:"base_dn,optional"`
+	BaseDNs                        []string            `hcl:"base_dns,optional"`
+	TableBaseDNs                   map[string][]string `hcl:"table_base_dns,optional"`
+	Pagination                     *string             `hcl:"pagination,optional"`
+	PageSize                       *int                `hcl:"page_size,optional"`
+	TablePageSizes                 map[string]int      `hcl:"table_page_sizes,optional"`
+	Username                       *string             `hcl:"username"`
+	Password                       *string             `hcl:"password"`
+	Host                           *string             `hcl:"host"`
+	DirectoryType                  *string             `hcl:"directory_type,optional"`
+	Port                           *string             `hcl:"port,optional"`
+	TLSRequired                    *bool               `hcl:"tls_required"`
+	FollowReferrals                *bool               `hcl:"follow_referrals,optional"`
+	ReferralHopLimit               *int                `hcl:"referral_hop_limit,optional"`
+	ReferralCredentials            *string             `hcl:"referral_credentials,optional"`
+	GlobalCatalog                  *bool               `hcl:"global_catalog,optional"`
+	ForestMode                     *bool               `hcl:"forest_mode,optional"`
+	UserObjectFilter               *string             `hcl:"user_object_filter"`
+	GroupObjectFilter              *string             `hcl:"group_object_filter"`
+	OrganizationalUnitObjectFilter *string             `hcl:"ou_object_filter"`
+	PosixAccountObjectFilter       *string             `hcl:"posix_account_object_filter"`
+	PosixGroupObjectFilter         *string             `hcl:"posix_group_object_filter"`
+	ColumnAttributes               map[string]string   `hcl:"column_attributes,optional"`
+	SchemaTables                   []string            `hcl:"schema_tables,optional"`
+	SchemaTablesExclude            []string            `hcl:"schema_tables_exclude,optional"`
+	SyncStateDir                   *string             `hcl:"sync_state_dir,optional"`
+	Tables                         []customTableConfig `hcl:"table,block"`
+}
+
+// customTableConfig describes a table declared in the connection config with a table block
+type customTableConfig struct {
+	Name         string               `hcl:"name,label"`
+	Description  *string              `hcl:"description,optional"`
+	ObjectFilter string               `hcl:"object_filter"`
+	Columns      []customColumnConfig `hcl:"column,block"`
+}
+
+// customColumnConfig describes a column of a custom table. The attribute defaults to the column name
+// and the type to string.
+type customColumnConfig struct {
+	Name        string  `hcl:"name,label"`
+	Attribute   *string `hcl:"attribute,optional"`
+	Type        *string `hcl:"type,optional"`
+	Description *string `hcl:"description,optional"`
 }
 
 func ConfigInstance() interface{} {
 	return &ldapConfig{}
 }
 
+// baseDNs returns the search bases of a table, i.e. the bases set for the table in table_base_dns,
+// or else base_dn followed by base_dns
+func (c ldapConfig) baseDNs(table string) []string {
+	if tableBaseDNs, ok := c.TableBaseDNs[table]; ok && len(tableBaseDNs) > 0 {
+		return tableBaseDNs
+	}
+
+	var baseDNs []string
+	if c.BaseDN != nil && *c.BaseDN != "" {
+		baseDNs = append(baseDNs, *c.BaseDN)
+	}
+	for _, baseDN := range c.BaseDNs {
+		duplicate := false
+		for _, existing := range baseDNs {
+			duplicate = duplicate || normalizeDN(existing) == normalizeDN(baseDN)
+		}
+		if !duplicate {
+			baseDNs = append(baseDNs, baseDN)
+		}
+	}
+	return baseDNs
+}
+
+// baseDNOf returns the search base of a table under which an entry is located, or the first search base
+// of the table if the entry is outside all of them
+func (c ldapConfig) baseDNOf(table string, dn string) string {
+	baseDNs := c.baseDNs(table)
+	for _, baseDN := range baseDNs {
+		if isDescendantDN(dn, baseDN) {
+			return baseDN
+		}
+	}
+	if len(baseDNs) > 0 {
+		return baseDNs[0]
+	}
+	return ""
+}
+
+// pageSize returns the number of entries to request per page for a table, i.e. the size set for the table
+// in table_page_sizes, or else page_size, or else the default page size
+func (c ldapConfig) pageSize(table string) (uint32, error) {
+	pageSize := int(PageSize)
+	if tablePageSize, ok := c.TablePageSizes[table]; ok {
+		pageSize = tablePageSize
+	} else if c.PageSize != nil {
+		pageSize = *c.PageSize
+	}
+	if pageSize < 1 {
+		return 0, fmt.Errorf("page size of table %q must be greater than 0, got %d. Edit your connection configuration file and then restart Steampipe", table, pageSize)
+	}
+	return uint32(pageSize), nil
+}
+
+// declaresTable returns whether a table block with the given name is set in the connection config
+func (c ldapConfig) declaresTable(name string) bool {
+	for _, tableConfig := range c.Tables {
+		if tableConfig.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // GetConfig :: retrieve and cast connection config from query data
 func GetConfig(connection *plugin.Connection) ldapConfig {
 	if connection == nil || connection.Config == nil {
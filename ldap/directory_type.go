@@ -0,0 +1,341 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/iancoleman/strcase"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// Supported values for the directory_type connection config argument
+const (
+	DirectoryTypeAuto            = "auto"
+	DirectoryTypeActiveDirectory = "active_directory"
+	DirectoryTypeOpenLDAP        = "openldap"
+	DirectoryType389DS           = "389ds"
+	DirectoryTypeFreeIPA         = "freeipa"
+)
+
+// LDAP_CAP_ACTIVE_DIRECTORY_OID, advertised in supportedCapabilities by every AD domain controller
+// Refer - https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/3ed61e6c-cfdc-487b-b6d1-e8ea3e6d4ae7
+const ActiveDirectoryCapability = "1.2.840.113556.1.4.800"
+
+// A directoryProfile describes how the columns of the user, group and organizational unit tables
+// map to the attributes and object classes used by a particular directory server flavor
+type directoryProfile struct {
+	// One of the DirectoryType* constants, other than auto
+	Type string
+	// Default object filters used when no filter is set in the connection config
+	UserObjectFilter               string
+	GroupObjectFilter              string
+	OrganizationalUnitObjectFilter string
+	// Filter matching disabled or locked user accounts
+	DisabledUserFilter string
+	// Operational attributes that must be requested explicitly to populate columns
+	OperationalAttributes []string
+	// Column name to LDAP attribute name mapping shared by all tables
+	ColumnAttributes map[string]string
+	// Column name to LDAP attribute name mapping for specific tables, which takes precedence over ColumnAttributes
+	TableColumnAttributes map[string]map[string]string
+}
+
+var directoryProfiles = map[string]*directoryProfile{
+	DirectoryTypeActiveDirectory: {
+		Type:                           DirectoryTypeActiveDirectory,
+		UserObjectFilter:               "(&(objectCategory=person)(objectClass=user))",
+		GroupObjectFilter:              "(objectClass=group)",
+		OrganizationalUnitObjectFilter: "(objectClass=organizationalUnit)",
+		DisabledUserFilter:             DisabledUserFilter,
+		ColumnAttributes: map[string]string{
+			"department":          "department",
+			"object_guid":         "objectGUID",
+			"object_sid":          "objectSid",
+			"sam_account_name":    "sAMAccountName",
+			"user_principal_name": "userPrincipalName",
+			"when_changed":        "whenChanged",
+			"when_created":        "whenCreated",
+		},
+	},
+	DirectoryTypeOpenLDAP: {
+		Type:                           DirectoryTypeOpenLDAP,
+		UserObjectFilter:               "(objectClass=inetOrgPerson)",
+		GroupObjectFilter:              "(|(objectClass=groupOfNames)(objectClass=groupOfUniqueNames)(objectClass=posixGroup))",
+		OrganizationalUnitObjectFilter: "(objectClass=organizationalUnit)",
+		DisabledUserFilter:             "(pwdAccountLockedTime=*)",
+		OperationalAttributes:          []string{"createTimestamp", "modifyTimestamp", "pwdAccountLockedTime"},
+		ColumnAttributes: map[string]string{
+			"department":       "departmentNumber",
+			"object_guid":      "entryUUID",
+			"sam_account_name": "uid",
+			"when_changed":     "modifyTimestamp",
+			"when_created":     "createTimestamp",
+		},
+		TableColumnAttributes: map[string]map[string]string{
+			"ldap_group": {"sam_account_name": "cn"},
+		},
+	},
+	DirectoryType389DS: {
+		Type:                           DirectoryType389DS,
+		UserObjectFilter:               "(objectClass=inetOrgPerson)",
+		GroupObjectFilter:              "(|(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))",
+		OrganizationalUnitObjectFilter: "(objectClass=organizationalUnit)",
+		DisabledUserFilter:             "(nsAccountLock=TRUE)",
+		OperationalAttributes:          []string{"createTimestamp", "modifyTimestamp", "nsAccountLock"},
+		ColumnAttributes: map[string]string{
+			"department":       "departmentNumber",
+			"object_guid":      "entryUUID",
+			"sam_account_name": "uid",
+			"when_changed":     "modifyTimestamp",
+			"when_created":     "createTimestamp",
+		},
+		TableColumnAttributes: map[string]map[string]string{
+			"ldap_group": {"sam_account_name": "cn"},
+		},
+	},
+	DirectoryTypeFreeIPA: {
+		Type:                           DirectoryTypeFreeIPA,
+		UserObjectFilter:               "(&(objectClass=person)(objectClass=posixAccount))",
+		GroupObjectFilter:              "(objectClass=ipaUserGroup)",
+		OrganizationalUnitObjectFilter: "(objectClass=organizationalUnit)",
+		DisabledUserFilter:             "(nsAccountLock=TRUE)",
+		OperationalAttributes:          []string{"createTimestamp", "modifyTimestamp", "nsAccountLock"},
+		ColumnAttributes: map[string]string{
+			"department":          "departmentNumber",
+			"object_guid":         "ipaUniqueID",
+			"object_sid":          "ipaNTSecurityIdentifier",
+			"sam_account_name":    "uid",
+			"user_principal_name": "krbPrincipalName",
+			"when_changed":        "modifyTimestamp",
+			"when_created":        "createTimestamp",
+		},
+		TableColumnAttributes: map[string]map[string]string{
+			"ldap_group": {"sam_account_name": "cn"},
+		},
+	},
+}
+
+// attributeName returns the LDAP attribute backing a column of a table. Columns without an explicit
+// mapping use the lower camel case form of the column name, e.g. display_name -> displayName.
+// A nil profile only applies the default mapping.
+func (p *directoryProfile) attributeName(table string, column string) string {
+	if p != nil {
+		if attribute, ok := p.TableColumnAttributes[table][column]; ok {
+			return attribute
+		}
+		if attribute, ok := p.ColumnAttributes[column]; ok {
+			return attribute
+		}
+	}
+	if ldapDisplayNames[column] != "" {
+		return ldapDisplayNames[column]
+	}
+	return strcase.ToLowerCamel(column)
+}
+
+// withColumnAttributes returns a copy of the profile with the column to attribute mappings set in the
+// column_attributes connection config argument, keyed by column name or by table and column name, e.g. ldap_user.mail
+func (p *directoryProfile) withColumnAttributes(columnAttributes map[string]string) *directoryProfile {
+	copied := *p
+	profile := &copied
+
+	// Copy the mappings of the profile, since profiles are shared by all connections
+	profileColumnAttributes, profileTableColumnAttributes := profile.ColumnAttributes, profile.TableColumnAttributes
+	profile.ColumnAttributes = map[string]string{}
+	for column, attribute := range profileColumnAttributes {
+		profile.ColumnAttributes[column] = attribute
+	}
+	profile.TableColumnAttributes = map[string]map[string]string{}
+	for table, tableColumnAttributes := range profileTableColumnAttributes {
+		profile.TableColumnAttributes[table] = map[string]string{}
+		for column, attribute := range tableColumnAttributes {
+			profile.TableColumnAttributes[table][column] = attribute
+		}
+	}
+
+	// Overrides keyed by column name apply to every table, so they replace the table specific mappings of the profile
+	for key, attribute := range columnAttributes {
+		if strings.Contains(key, ".") {
+			continue
+		}
+		profile.ColumnAttributes[key] = attribute
+		for _, tableColumnAttributes := range profile.TableColumnAttributes {
+			delete(tableColumnAttributes, key)
+		}
+	}
+	for key, attribute := range columnAttributes {
+		table, column, found := strings.Cut(key, ".")
+		if !found {
+			continue
+		}
+		if profile.TableColumnAttributes[table] == nil {
+			profile.TableColumnAttributes[table] = map[string]string{}
+		}
+		profile.TableColumnAttributes[table][column] = attribute
+	}
+
+	return profile
+}
+
+// attributeValue returns the value of the attribute backing a column of a table
+func (p *directoryProfile) attributeValue(table string, column string, entry *ldap.Entry) string {
+	return entryAttributeValue(entry, p.attributeName(table, column))
+}
+
+// entryAttributeValue returns the value of an attribute of an entry.
+// For proxyAddresses, the primary SMTP address is returned, i.e. the one with an upper case SMTP: prefix
+func entryAttributeValue(entry *ldap.Entry, attribute string) string {
+	if strings.EqualFold(attribute, "proxyAddresses") {
+		return primaryProxyAddress(entry.GetEqualFoldAttributeValues(attribute))
+	}
+	return entry.GetEqualFoldAttributeValue(attribute)
+}
+
+// isDisabled returns whether a user account is disabled or locked, according to the attributes used by the directory flavor
+func (p *directoryProfile) isDisabled(ctx context.Context, entry *ldap.Entry) *bool {
+	var disabled bool
+	switch p.Type {
+	case DirectoryTypeActiveDirectory:
+		return verifyUserDisabled(ctx, entry)
+	case DirectoryTypeOpenLDAP:
+		disabled = entry.GetEqualFoldAttributeValue("pwdAccountLockedTime") != ""
+	default:
+		value := entry.GetEqualFoldAttributeValue("nsAccountLock")
+		if value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				plugin.Logger(ctx).Error("ldap_directory_type.isDisabled", "Error while converting nsAccountLock to bool", err)
+				return nil
+			}
+			disabled = parsed
+		}
+	}
+	return &disabled
+}
+
+// requestAttributes returns all user attributes along with the operational attributes needed by the profile and the table.
+// A nil profile only adds those of the table
+func (p *directoryProfile) requestAttributes(tableAttributes ...string) []string {
+	attributes := []string{"*"}
+	if p != nil {
+		attributes = append(attributes, p.OperationalAttributes...)
+	}
+	return append(attributes, tableAttributes...)
+}
+
+// disabledAttributes returns the attributes isDisabled reads to tell whether a user account is disabled or locked
+func (p *directoryProfile) disabledAttributes() []string {
+	switch p.Type {
+	case DirectoryTypeActiveDirectory:
+		return []string{"userAccountControl"}
+	case DirectoryTypeOpenLDAP:
+		return []string{"pwdAccountLockedTime"}
+	default:
+		return []string{"nsAccountLock"}
+	}
+}
+
+// getDirectoryProfile returns the profile for the directory_type set in the connection config,
+// detecting the directory flavor from the root DSE if it is not set or set to auto
+func getDirectoryProfile(ctx context.Context, d *plugin.QueryData) (*directoryProfile, error) {
+	directoryType := DirectoryTypeAuto
+
+	ldapConfig := GetConfig(d.Connection)
+	if ldapConfig.DirectoryType != nil && *ldapConfig.DirectoryType != "" {
+		directoryType = *ldapConfig.DirectoryType
+	}
+
+	if directoryType == DirectoryTypeAuto {
+		// Load detected type from cache
+		cacheKey := "ldap_directory_type"
+		if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
+			directoryType = cachedData.(string)
+		} else {
+			detected, err := detectDirectoryType(ctx, d)
+			if err != nil {
+				return nil, err
+			}
+			directoryType = detected
+			d.ConnectionManager.Cache.Set(cacheKey, directoryType)
+		}
+	}
+
+	profile, ok := directoryProfiles[directoryType]
+	if !ok {
+		return nil, fmt.Errorf("'directory_type' must be one of %q, %q, %q, %q or %q, got %q. Edit your connection configuration file and then restart Steampipe", DirectoryTypeAuto, DirectoryTypeActiveDirectory, DirectoryTypeOpenLDAP, DirectoryType389DS, DirectoryTypeFreeIPA, directoryType)
+	}
+
+	if len(ldapConfig.ColumnAttributes) > 0 {
+		profile = profile.withColumnAttributes(ldapConfig.ColumnAttributes)
+	}
+
+	return profile, nil
+}
+
+func detectDirectoryType(ctx context.Context, d *plugin.QueryData) (string, error) {
+	logger := plugin.Logger(ctx)
+
+	rootDSE, err := getRootDSE(ctx, d)
+	if err != nil {
+		return "", err
+	}
+
+	directoryType := DirectoryTypeActiveDirectory
+	vendorName := strings.ToLower(rootDSE.GetEqualFoldAttributeValue("vendorName"))
+
+	switch {
+	case containsEqualFold(rootDSE.GetEqualFoldAttributeValues("supportedCapabilities"), ActiveDirectoryCapability):
+		directoryType = DirectoryTypeActiveDirectory
+	case rootDSE.GetEqualFoldAttributeValue("ipaTopologyPluginVersion") != "":
+		directoryType = DirectoryTypeFreeIPA
+	case strings.Contains(vendorName, "389") || strings.Contains(vendorName, "fedora") || strings.Contains(vendorName, "red hat"):
+		directoryType = DirectoryType389DS
+	case containsEqualFold(rootDSE.GetEqualFoldAttributeValues("objectClass"), "OpenLDAProotDSE"):
+		directoryType = DirectoryTypeOpenLDAP
+	default:
+		// Keep the historical behaviour of the plugin if the server cannot be identified
+		logger.Warn("ldap_directory_type.detectDirectoryType", "unknown_directory", "falling back to active_directory", "vendorName", vendorName)
+	}
+
+	logger.Debug("ldap_directory_type.detectDirectoryType", "directory_type", directoryType)
+
+	return directoryType, nil
+}
+
+// primaryProxyAddress returns the primary SMTP address of a proxyAddresses attribute, without its prefix
+func primaryProxyAddress(proxyAddresses []string) string {
+	for _, address := range proxyAddresses {
+		if strings.HasPrefix(address, "SMTP:") {
+			return strings.TrimPrefix(address, "SMTP:")
+		}
+	}
+	return ""
+}
+
+// filterValue returns the escaped value to match in a filter on an attribute (RFC 4515), so that characters such as
+// *, ( and ) and the backslashes of DNs are matched literally. Matching on proxyAddresses is case insensitive,
+// so an smtp: prefix matches both primary and secondary addresses.
+// GUIDs are matched against the binary objectGUID of AD, which is already encoded
+func filterValue(attribute string, value string) string {
+	if strings.EqualFold(attribute, "proxyAddresses") && !strings.Contains(value, ":") {
+		value = "smtp:" + value
+	}
+	if strings.EqualFold(attribute, "objectGUID") {
+		if guid, ok := objectGUIDFilterValue(value); ok {
+			return guid
+		}
+	}
+	return ldap.EscapeFilter(value)
+}
+
+func containsEqualFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
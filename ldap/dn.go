@@ -0,0 +1,280 @@
+package ldap
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// A parsedDN is a distinguished name parsed according to RFC 4514, along with each of its RDNs as written
+// in the DN, so that the DNs derived from it, e.g. its parent, keep the form returned by the server and can
+// be joined with the dn column of other tables
+type parsedDN struct {
+	*ldap.DN
+	// RDNs as written in the DN, from the RDN of the entry up to the root
+	rawRDNs []string
+}
+
+// A dnComponent is an attribute type and value of an RDN, as reported by the dn_components column
+type dnComponent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// parseDN parses a DN, e.g. CN=Smith\, Bob,OU=Users,DC=example,DC=com or CN="Smith, Bob",OU=Users,DC=example,DC=com
+func parseDN(dn string) (*parsedDN, error) {
+	parsed, err := ldap.ParseDN(unquoteDN(dn))
+	if err != nil {
+		return nil, err
+	}
+	return &parsedDN{DN: parsed, rawRDNs: splitRDNs(dn)}, nil
+}
+
+// unquoteDN rewrites the quoted attribute values of a DN, which ParseDN doesn't support, with escapes instead,
+// e.g. CN="Smith, Bob",DC=example,DC=com -> CN=Smith\, Bob,DC=example,DC=com. DNs with an unterminated quote are left as is
+func unquoteDN(dn string) string {
+	if !strings.Contains(dn, `"`) {
+		return dn
+	}
+
+	var unquoted strings.Builder
+	inValue := false
+	valueStart := false
+	for i := 0; i < len(dn); i++ {
+		c := dn[i]
+		switch {
+		case c == '\\' && i+1 < len(dn):
+			unquoted.WriteString(dn[i : i+2])
+			i++
+			valueStart = false
+		case c == '"' && valueStart:
+			end := closingQuote(dn, i+1)
+			if end < 0 {
+				return dn
+			}
+			unquoted.WriteString(escapeDNValue(dn[i+1 : end]))
+			i = end
+			valueStart = false
+		case c == '=' && !inValue:
+			unquoted.WriteByte(c)
+			inValue, valueStart = true, true
+		case c == ',' || c == ';' || c == '+':
+			unquoted.WriteByte(c)
+			inValue, valueStart = false, false
+		case c == ' ' && valueStart:
+			unquoted.WriteByte(c)
+		default:
+			unquoted.WriteByte(c)
+			valueStart = false
+		}
+	}
+	return unquoted.String()
+}
+
+// closingQuote returns the index of the quote which is not escaped closing a quoted value starting at start, or -1
+func closingQuote(dn string, start int) int {
+	for i := start; i < len(dn); i++ {
+		switch dn[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// escapeDNValue escapes the special characters of the content of a quoted attribute value (RFC 4514),
+// keeping the escapes already in it
+func escapeDNValue(value string) string {
+	var escaped strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value):
+			escaped.WriteString(value[i : i+2])
+			i++
+			continue
+		case strings.IndexByte(`,+;<>"\`, c) >= 0,
+			i == 0 && (c == '#' || c == ' '),
+			i == len(value)-1 && c == ' ':
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(c)
+	}
+	return escaped.String()
+}
+
+// splitRDNs splits a DN into its RDNs at the separators which are neither escaped nor quoted, without decoding them
+func splitRDNs(dn string) []string {
+	if strings.TrimSpace(dn) == "" {
+		return nil
+	}
+
+	var rdns []string
+	start := 0
+	escaping := false
+	quoted := false
+	for i := 0; i < len(dn); i++ {
+		switch {
+		case escaping:
+			escaping = false
+		case dn[i] == '\\':
+			escaping = true
+		case dn[i] == '"':
+			quoted = !quoted
+		case (dn[i] == ',' || dn[i] == ';') && !quoted:
+			rdns = append(rdns, trimRDN(dn[start:i]))
+			start = i + 1
+		}
+	}
+	return append(rdns, trimRDN(dn[start:]))
+}
+
+// trimRDN removes the spaces around an RDN, except for an escaped trailing space
+func trimRDN(rdn string) string {
+	trimmed := strings.TrimRight(rdn, " ")
+	if strings.HasSuffix(trimmed, "\\") && len(trimmed) < len(rdn) {
+		trimmed += " "
+	}
+	return strings.TrimLeft(trimmed, " ")
+}
+
+// ancestor returns the DN of the ancestor the given number of levels up, as written in the DN
+func (p *parsedDN) ancestor(levels int) string {
+	if levels >= len(p.rawRDNs) {
+		return ""
+	}
+	return strings.Join(p.rawRDNs[levels:], ",")
+}
+
+// parent returns the DN of the container of the entry, or an empty string for a naming context with a single RDN
+func (p *parsedDN) parent() string {
+	return p.ancestor(1)
+}
+
+// organizationalUnit returns the DN of the nearest organizational unit containing the entry, if any, or of the
+// entry itself if it is an organizational unit, e.g. CN=Lou Smith,OU=Sales,DC=example,DC=com -> OU=Sales,DC=example,DC=com
+func (p *parsedDN) organizationalUnit() string {
+	for i := 0; i < len(p.RDNs); i++ {
+		for _, attribute := range p.RDNs[i].Attributes {
+			if strings.EqualFold(attribute.Type, "OU") {
+				return p.ancestor(i)
+			}
+		}
+	}
+	return ""
+}
+
+// domain returns the DNS name of the domain of the entry, built from its domain components,
+// e.g. CN=Bob,OU=Users,DC=corp,DC=example,DC=com -> corp.example.com
+func (p *parsedDN) domain() string {
+	var components []string
+	for _, rdn := range p.RDNs {
+		for _, attribute := range rdn.Attributes {
+			if strings.EqualFold(attribute.Type, "DC") {
+				components = append(components, attribute.Value)
+			}
+		}
+	}
+	return strings.ToLower(strings.Join(components, "."))
+}
+
+// components returns the decoded attribute types and values of the RDNs of the DN, from the entry up to the root.
+// The attributes of a multi-valued RDN are listed one after the other
+func (p *parsedDN) components() []dnComponent {
+	components := []dnComponent{}
+	for _, rdn := range p.RDNs {
+		for _, attribute := range rdn.Attributes {
+			components = append(components, dnComponent{Type: attribute.Type, Value: attribute.Value})
+		}
+	}
+	return components
+}
+
+// normalizeDN returns a form of a DN suitable for comparisons, in which the case, spacing, quoting and escaping
+// of the DN and the order of the attributes of multi-valued RDNs are not significant.
+// DNs that cannot be parsed are only lowercased
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(unquoteDN(dn))
+	if err != nil {
+		return strings.ToLower(dn)
+	}
+	return strings.ToLower(parsed.String())
+}
+
+// isDescendantDN returns whether a DN is the base DN or lies beneath it. An empty base DN contains all DNs
+func isDescendantDN(dn string, baseDN string) bool {
+	if strings.TrimSpace(baseDN) == "" {
+		return true
+	}
+	parsedBase, err := ldap.ParseDN(unquoteDN(baseDN))
+	if err != nil {
+		return false
+	}
+	parsed, err := ldap.ParseDN(unquoteDN(dn))
+	if err != nil {
+		return false
+	}
+	return parsedBase.EqualFold(parsed) || parsedBase.AncestorOfFold(parsed)
+}
+
+// getOrganizationUnit returns the DN of the nearest organizational unit containing an entry, or of the entry itself
+// if it is an organizational unit
+func getOrganizationUnit(dn string) string {
+	parsed, err := parseDN(dn)
+	if err != nil {
+		return ""
+	}
+	return parsed.organizationalUnit()
+}
+
+// transformDNComponents returns the attribute types and values of the RDNs of the DN of a row
+func transformDNComponents(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	dn, ok := d.Value.(string)
+	if !ok || dn == "" {
+		return nil, nil
+	}
+	parsed, err := parseDN(dn)
+	if err != nil {
+		return nil, nil
+	}
+	return parsed.components(), nil
+}
+
+// transformNormalizedDN returns the normalized form of the DN of a row
+func transformNormalizedDN(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	dn, ok := d.Value.(string)
+	if !ok || dn == "" {
+		return nil, nil
+	}
+	return normalizeDN(dn), nil
+}
+
+// transformParentDN returns the DN of the container of the DN of a row
+func transformParentDN(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	dn, ok := d.Value.(string)
+	if !ok || dn == "" {
+		return nil, nil
+	}
+	parsed, err := parseDN(dn)
+	if err != nil {
+		return nil, nil
+	}
+	return parsed.parent(), nil
+}
+
+// transformDepth returns the number of RDNs of the DN of a row
+func transformDepth(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	dn, ok := d.Value.(string)
+	if !ok || dn == "" {
+		return nil, nil
+	}
+	parsed, err := parseDN(dn)
+	if err != nil {
+		return nil, nil
+	}
+	return len(parsed.RDNs), nil
+}
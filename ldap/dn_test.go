@@ -0,0 +1,122 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDN(t *testing.T) {
+	tests := []struct {
+		name           string
+		dn             string
+		wantParent     string
+		wantOU         string
+		wantDomain     string
+		wantComponents []dnComponent
+	}{
+		{
+			name:       "plain",
+			dn:         "CN=Bob,OU=Users,DC=corp,DC=example,DC=com",
+			wantParent: "OU=Users,DC=corp,DC=example,DC=com",
+			wantOU:     "OU=Users,DC=corp,DC=example,DC=com",
+			wantDomain: "corp.example.com",
+			wantComponents: []dnComponent{
+				{Type: "CN", Value: "Bob"}, {Type: "OU", Value: "Users"}, {Type: "DC", Value: "corp"}, {Type: "DC", Value: "example"}, {Type: "DC", Value: "com"},
+			},
+		},
+		{
+			name:       "escaped comma",
+			dn:         `CN=Smith\, Bob,OU=Sales,DC=example,DC=com`,
+			wantParent: "OU=Sales,DC=example,DC=com",
+			wantOU:     "OU=Sales,DC=example,DC=com",
+			wantDomain: "example.com",
+			wantComponents: []dnComponent{
+				{Type: "CN", Value: "Smith, Bob"}, {Type: "OU", Value: "Sales"}, {Type: "DC", Value: "example"}, {Type: "DC", Value: "com"},
+			},
+		},
+		{
+			name:       "quoted value",
+			dn:         `CN="Smith, Bob",OU="Sales; EMEA",DC=example,DC=com`,
+			wantParent: `OU="Sales; EMEA",DC=example,DC=com`,
+			wantOU:     `OU="Sales; EMEA",DC=example,DC=com`,
+			wantDomain: "example.com",
+			wantComponents: []dnComponent{
+				{Type: "CN", Value: "Smith, Bob"}, {Type: "OU", Value: "Sales; EMEA"}, {Type: "DC", Value: "example"}, {Type: "DC", Value: "com"},
+			},
+		},
+		{
+			name:       "quoted value with an escaped quote",
+			dn:         `CN="Bob \"The Builder\"",DC=example,DC=com`,
+			wantParent: "DC=example,DC=com",
+			wantDomain: "example.com",
+			wantComponents: []dnComponent{
+				{Type: "CN", Value: `Bob "The Builder"`}, {Type: "DC", Value: "example"}, {Type: "DC", Value: "com"},
+			},
+		},
+		{
+			name:       "OU in a CN is not an organizational unit",
+			dn:         "CN=Lou Smith,CN=Users,DC=example,DC=com",
+			wantParent: "CN=Users,DC=example,DC=com",
+			wantDomain: "example.com",
+			wantComponents: []dnComponent{
+				{Type: "CN", Value: "Lou Smith"}, {Type: "CN", Value: "Users"}, {Type: "DC", Value: "example"}, {Type: "DC", Value: "com"},
+			},
+		},
+		{
+			name:       "organizational unit",
+			dn:         "OU=EMEA,OU=Sales,DC=example,DC=com",
+			wantParent: "OU=Sales,DC=example,DC=com",
+			wantOU:     "OU=EMEA,OU=Sales,DC=example,DC=com",
+			wantDomain: "example.com",
+			wantComponents: []dnComponent{
+				{Type: "OU", Value: "EMEA"}, {Type: "OU", Value: "Sales"}, {Type: "DC", Value: "example"}, {Type: "DC", Value: "com"},
+			},
+		},
+		{
+			name:           "naming context",
+			dn:             "O=Example",
+			wantComponents: []dnComponent{{Type: "O", Value: "Example"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parsed, err := parseDN(test.dn)
+			if err != nil {
+				t.Fatalf("parseDN(%s) returned error: %v", test.dn, err)
+			}
+			if got := parsed.parent(); got != test.wantParent {
+				t.Errorf("parent() = %s, want %s", got, test.wantParent)
+			}
+			if got := parsed.organizationalUnit(); got != test.wantOU {
+				t.Errorf("organizationalUnit() = %s, want %s", got, test.wantOU)
+			}
+			if got := parsed.domain(); got != test.wantDomain {
+				t.Errorf("domain() = %s, want %s", got, test.wantDomain)
+			}
+			if got := parsed.components(); !reflect.DeepEqual(got, test.wantComponents) {
+				t.Errorf("components() = %v, want %v", got, test.wantComponents)
+			}
+		})
+	}
+}
+
+func TestNormalizeDN(t *testing.T) {
+	tests := []struct {
+		dn   string
+		want string
+	}{
+		{dn: "CN=Bob,OU=Users,DC=example,DC=com", want: "cn=bob,ou=users,dc=example,dc=com"},
+		{dn: "cn=Bob , ou=Users,dc=Example,dc=COM", want: "cn=bob,ou=users,dc=example,dc=com"},
+		{dn: `CN="Smith, Bob",DC=example,DC=com`, want: `cn=smith\, bob,dc=example,dc=com`},
+		{dn: `CN=Smith\2C Bob,DC=example,DC=com`, want: `cn=smith\, bob,dc=example,dc=com`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.dn, func(t *testing.T) {
+			if got := normalizeDN(test.dn); got != test.want {
+				t.Errorf("normalizeDN(%s) = %s, want %s", test.dn, got, test.want)
+			}
+		})
+	}
+}
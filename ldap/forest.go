@@ -0,0 +1,134 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Filter matching the crossRef objects of the domains of a forest, i.e. with the FLAG_CR_NTDS_DOMAIN bit of systemFlags set
+// Refer - https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/1e38247d-8234-4273-9de3-bbf313548631
+const DomainCrossRefFilter = "(&(objectClass=crossRef)(systemFlags:1.2.840.113556.1.4.803:=2))"
+
+// A forestDomain is a domain of an Active Directory forest, as described by its crossRef object
+type forestDomain struct {
+	// Distinguished name of the domain naming context
+	DN string
+	// DNS name of the domain, which resolves to its domain controllers
+	DNSName string
+	// NetBIOS name of the domain
+	NetBIOSName string
+}
+
+func isForestMode(d *plugin.QueryData) bool {
+	ldapConfig := GetConfig(d.Connection)
+	return ldapConfig.ForestMode != nil && *ldapConfig.ForestMode
+}
+
+// getForestDomains discovers the domains of the forest from the crossRef objects of the partitions container
+// in the configuration naming context
+func getForestDomains(ctx context.Context, d *plugin.QueryData) ([]forestDomain, error) {
+	logger := plugin.Logger(ctx)
+
+	// Load domains from cache
+	cacheKey := "ldap_forest_domains"
+	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
+		return cachedData.([]forestDomain), nil
+	}
+
+	rootDSE, err := getRootDSE(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	configurationNC := rootDSE.GetEqualFoldAttributeValue("configurationNamingContext")
+	if configurationNC == "" {
+		return nil, errors.New("'forest_mode' requires an Active Directory server advertising a configuration naming context in its root DSE")
+	}
+
+	// Search the configured host directly, as routing searches to domain controllers relies on the domains
+	searchReq := ldap.NewSearchRequest("CN=Partitions,"+configurationNC, ldap.ScopeSingleLevel, ldap.NeverDerefAliases, 0, 0, false, DomainCrossRefFilter, []string{"nCName", "dnsRoot", "nETBIOSName"}, []ldap.Control{})
+	result, err := searchHost(ctx, d, "", searchReq)
+	if err != nil {
+		logger.Error("ldap_forest.getForestDomains", "search_error", err)
+		return nil, err
+	}
+
+	var domains []forestDomain
+	for _, entry := range result.Entries {
+		domains = append(domains, forestDomain{
+			DN:          entry.GetEqualFoldAttributeValue("nCName"),
+			DNSName:     entry.GetEqualFoldAttributeValue("dnsRoot"),
+			NetBIOSName: entry.GetEqualFoldAttributeValue("nETBIOSName"),
+		})
+	}
+
+	logger.Debug("ldap_forest.getForestDomains", "domains", domains)
+
+	d.ConnectionManager.Cache.Set(cacheKey, domains)
+
+	return domains, nil
+}
+
+// hostForDN returns the host to send searches under a DN to. In forest mode, this is the DNS name of the
+// innermost domain containing the DN, which resolves to its domain controllers. Otherwise, or when the DN is
+// outside all domains, e.g. the root DSE or the configuration naming context, the configured host is used
+func hostForDN(ctx context.Context, d *plugin.QueryData, dn string) (string, error) {
+	if !isForestMode(d) || dn == "" {
+		return "", nil
+	}
+
+	domains, err := getForestDomains(ctx, d)
+	if err != nil {
+		return "", err
+	}
+
+	var host, domainDN string
+	for _, domain := range domains {
+		if isDescendantDN(dn, domain.DN) && len(domain.DN) > len(domainDN) {
+			host, domainDN = domain.DNSName, domain.DN
+		}
+	}
+
+	return host, nil
+}
+
+// forestBaseDNs returns the naming contexts of all the domains of the forest
+func forestBaseDNs(ctx context.Context, d *plugin.QueryData) ([]string, error) {
+	domains, err := getForestDomains(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseDNs []string
+	for _, domain := range domains {
+		baseDNs = append(baseDNs, domain.DN)
+	}
+	return baseDNs, nil
+}
+
+// domainFromDN returns the DNS name of the domain of a DN, built from its domain components,
+// e.g. CN=Bob,OU=Users,DC=corp,DC=example,DC=com -> corp.example.com
+func domainFromDN(dn string) string {
+	parsed, err := parseDN(dn)
+	if err != nil {
+		return ""
+	}
+	return parsed.domain()
+}
+
+// transformDomain returns the DNS name of the domain of the DN of a row
+func transformDomain(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	dn, ok := d.Value.(string)
+	if !ok || dn == "" {
+		return nil, nil
+	}
+	domain := domainFromDN(dn)
+	if domain == "" {
+		return nil, nil
+	}
+	return domain, nil
+}
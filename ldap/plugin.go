@@ -2,6 +2,7 @@ package ldap
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
@@ -20,11 +21,55 @@ func Plugin(ctx context.Context) *plugin.Plugin {
 		ConnectionConfigSchema: &plugin.ConnectionConfigSchema{
 			NewInstance: ConfigInstance,
 		},
-		TableMap: map[string]*plugin.Table{
-			"ldap_group":               tableLDAPGroup(ctx),
-			"ldap_organizational_unit": tableLDAPOrganizationalUnit(ctx),
-			"ldap_user":                tableLDAPUser(ctx),
-		},
+		SchemaMode:   plugin.SchemaModeDynamic,
+		TableMapFunc: pluginTableDefinitions,
 	}
 	return p
 }
+
+func pluginTableDefinitions(ctx context.Context, td *plugin.TableMapData) (map[string]*plugin.Table, error) {
+	tables := map[string]*plugin.Table{
+		"ldap_change":                   tableLDAPChange(ctx),
+		"ldap_changelog":                tableLDAPChangelog(ctx),
+		"ldap_domain":                   tableLDAPDomain(ctx),
+		"ldap_group":                    tableLDAPGroup(ctx),
+		"ldap_organizational_unit":      tableLDAPOrganizationalUnit(ctx),
+		"ldap_password_settings_object": tableLDAPPasswordSettingsObject(ctx),
+		"ldap_posix_account":            tableLDAPPosixAccount(ctx),
+		"ldap_posix_group":              tableLDAPPosixGroup(ctx),
+		"ldap_ppolicy":                  tableLDAPPpolicy(ctx),
+		"ldap_tree":                     tableLDAPTree(ctx),
+		"ldap_user":                     tableLDAPUser(ctx),
+		"ldap_user_management_chain":    tableLDAPUserManagementChain(ctx),
+	}
+
+	ldapConfig := GetConfig(td.Connection)
+
+	// Add the tables generated from the directory schema, unless a table with the same name is declared in the connection config
+	for _, tableConfig := range schemaTableDefinitions(ctx, ldapConfig) {
+		if _, ok := tables[tableConfig.Name]; ok || ldapConfig.declaresTable(tableConfig.Name) {
+			plugin.Logger(ctx).Warn("pluginTableDefinitions", "skipping_schema_table", tableConfig.Name)
+			continue
+		}
+		table, err := tableLDAPCustom(ctx, tableConfig)
+		if err != nil {
+			plugin.Logger(ctx).Error("pluginTableDefinitions", "skipping_schema_table", tableConfig.Name, "error", err)
+			continue
+		}
+		tables[tableConfig.Name] = table
+	}
+
+	// Add the tables declared in the connection config
+	for _, tableConfig := range ldapConfig.Tables {
+		if _, ok := tables[tableConfig.Name]; ok {
+			return nil, fmt.Errorf("table %q is already defined. Edit your connection configuration file and then restart Steampipe", tableConfig.Name)
+		}
+		table, err := tableLDAPCustom(ctx, tableConfig)
+		if err != nil {
+			return nil, err
+		}
+		tables[tableConfig.Name] = table
+	}
+
+	return tables, nil
+}
@@ -0,0 +1,317 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// Supported values for the referral_credentials connection config argument
+const (
+	ReferralCredentialsReuse     = "reuse"
+	ReferralCredentialsAnonymous = "anonymous"
+)
+
+// Define the default maximum number of referrals followed in a row
+const DefaultReferralHopLimit = 5
+
+// A referral is a search to run on another server, parsed from an LDAP URL (RFC 4516) returned by a server
+type referral struct {
+	URL    string
+	Scheme string
+	Host   string
+	Port   string
+	BaseDN string
+	Scope  int
+	Filter string
+}
+
+// key identifies the search of a referral, to detect referral loops
+func (r *referral) key() string {
+	return strings.ToLower(fmt.Sprintf("%s:%s/%s?%d?%s", r.Host, r.Port, r.BaseDN, r.Scope, r.Filter))
+}
+
+// followReferrals runs the searches referred to by a search and passes the entries they return to handleEntry,
+// if follow_referrals is set in the connection config
+func followReferrals(ctx context.Context, d *plugin.QueryData, searchReq *ldap.SearchRequest, referrals []string, handleEntry func(entry *ldap.Entry)) error {
+	logger := plugin.Logger(ctx)
+
+	ldapConfig := GetConfig(d.Connection)
+	if ldapConfig.FollowReferrals == nil || !*ldapConfig.FollowReferrals {
+		logger.Debug("ldap_referral.followReferrals", "ignored_referrals", referrals)
+		return nil
+	}
+
+	if ldapConfig.ReferralCredentials != nil && *ldapConfig.ReferralCredentials != ReferralCredentialsReuse && *ldapConfig.ReferralCredentials != ReferralCredentialsAnonymous {
+		return fmt.Errorf("'referral_credentials' must be one of %q or %q, got %q. Edit your connection configuration file and then restart Steampipe", ReferralCredentialsReuse, ReferralCredentialsAnonymous, *ldapConfig.ReferralCredentials)
+	}
+
+	hopLimit := DefaultReferralHopLimit
+	if ldapConfig.ReferralHopLimit != nil {
+		hopLimit = *ldapConfig.ReferralHopLimit
+	}
+
+	// Referred searches are read in pages of the size of the search that returned the referrals
+	pageSize, err := configuredPageSize(d)
+	if err != nil {
+		return err
+	}
+	if paging, ok := ldap.FindControl(searchReq.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging); ok && paging.PagingSize > 0 {
+		pageSize = paging.PagingSize
+	}
+
+	chaseReferrals(ctx, d, searchReq, referrals, pageSize, handleEntry, map[string]bool{}, 1, hopLimit)
+
+	return nil
+}
+
+// chaseReferrals follows referrals recursively, up to the hop limit and skipping referrals already followed.
+// Referrals that cannot be followed are logged and skipped, so that a single unreachable server doesn't fail the query
+func chaseReferrals(ctx context.Context, d *plugin.QueryData, searchReq *ldap.SearchRequest, referrals []string, pageSize uint32, handleEntry func(entry *ldap.Entry), visited map[string]bool, hop int, hopLimit int) {
+	logger := plugin.Logger(ctx)
+
+	for _, referralURL := range referrals {
+		// Context can be cancelled due to manual cancellation or the limit has been hit
+		if d.RowsRemaining(ctx) == 0 {
+			return
+		}
+
+		r, err := parseReferral(referralURL, searchReq)
+		if err != nil {
+			logger.Warn("ldap_referral.chaseReferrals", "referral", referralURL, "parse_error", err)
+			continue
+		}
+		if visited[r.key()] {
+			logger.Warn("ldap_referral.chaseReferrals", "referral_loop", referralURL)
+			continue
+		}
+		visited[r.key()] = true
+		if hop > hopLimit {
+			logger.Warn("ldap_referral.chaseReferrals", "hop_limit_exceeded", referralURL, "hop_limit", hopLimit)
+			continue
+		}
+
+		logger.Debug("ldap_referral.chaseReferrals", "referral", referralURL, "hop", hop, "baseDN", r.BaseDN, "filter", r.Filter)
+
+		conn, err := connectReferral(ctx, d, r)
+		if err != nil {
+			logger.Warn("ldap_referral.chaseReferrals", "referral", referralURL, "connection_error", err)
+			continue
+		}
+
+		// Stream the pages of the referred search from the server of the referral, collecting the referrals it returns in turn
+		referredReq := ldap.NewSearchRequest(r.BaseDN, r.Scope, searchReq.DerefAliases, 0, 0, false, r.Filter, searchReq.Attributes, []ldap.Control{})
+		entries := 0
+		nested, err := pagedSearch(ctx, d, referredReq, pageSize, func(pageReq *ldap.SearchRequest, handlePageEntry func(entry *ldap.Entry)) (*ldap.SearchResult, error) {
+			result, _, err := streamResponse(ctx, d, conn, pageReq, handlePageEntry)
+			result, err = checkSearchResult(result, err)
+			if ldap.IsErrorWithCode(err, ldap.LDAPResultReferral) {
+				result.Referrals = append(result.Referrals, errorReferrals(err)...)
+				return result, nil
+			}
+			return result, err
+		}, func(entry *ldap.Entry) {
+			entries++
+			handleEntry(entry)
+		})
+		if err != nil {
+			logger.Warn("ldap_referral.chaseReferrals", "referral", referralURL, "search_error", err)
+			continue
+		}
+
+		logger.Debug("ldap_referral.chaseReferrals", "referral", referralURL, "entries", entries)
+
+		if len(nested) > 0 {
+			chaseReferrals(ctx, d, referredReq, nested, pageSize, handleEntry, visited, hop+1, hopLimit)
+		}
+	}
+}
+
+// parseReferral parses an LDAP URL, e.g. ldap://dc2.example.com/OU=Sales,DC=example,DC=com??sub?(objectClass=user).
+// Parts missing from the URL are taken from the search that returned it
+func parseReferral(referralURL string, searchReq *ldap.SearchRequest) (*referral, error) {
+	u, err := url.Parse(referralURL)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &referral{
+		URL:    referralURL,
+		Scheme: strings.ToLower(u.Scheme),
+		Host:   u.Hostname(),
+		Port:   u.Port(),
+		BaseDN: strings.TrimPrefix(u.Path, "/"),
+		Scope:  searchReq.Scope,
+		Filter: searchReq.Filter,
+	}
+
+	switch r.Scheme {
+	case "ldap":
+		if r.Port == "" {
+			r.Port = "389"
+		}
+	case "ldaps":
+		if r.Port == "" {
+			r.Port = "636"
+		}
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if r.Host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+	if r.BaseDN == "" {
+		r.BaseDN = searchReq.BaseDN
+	}
+
+	// Continuation references of a one level search refer to the children themselves (RFC 4511 section 4.5.3)
+	if searchReq.Scope == ldap.ScopeSingleLevel {
+		r.Scope = ldap.ScopeBaseObject
+	}
+
+	// The query holds attributes?scope?filter?extensions
+	parts := strings.Split(u.RawQuery, "?")
+	if len(parts) > 1 && parts[1] != "" {
+		scope, ok := searchScopes[strings.ToLower(parts[1])]
+		if !ok {
+			return nil, fmt.Errorf("invalid scope %q", parts[1])
+		}
+		r.Scope = scope
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		filter, err := url.QueryUnescape(parts[2])
+		if err != nil {
+			return nil, err
+		}
+		r.Filter = filter
+	}
+
+	return r, nil
+}
+
+// connectReferral connects to the server of a referral. Unless referral_credentials is set to reuse, the connection
+// is anonymous. Even then, the credentials of the connection config are only sent over TLS with a verified certificate
+// to hosts of the configured domains, so that a rogue or misconfigured server cannot collect them with a referral
+func connectReferral(ctx context.Context, d *plugin.QueryData, r *referral) (*ldap.Conn, error) {
+	logger := plugin.Logger(ctx)
+	address := net.JoinHostPort(r.Host, r.Port)
+
+	// Load connection from cache
+	cacheKey := "ldap_referral_" + strings.ToLower(r.Scheme+"://"+address)
+	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
+		return cachedData.(*ldap.Conn), nil
+	}
+
+	ldapConfig := GetConfig(d.Connection)
+	reuse := ldapConfig.ReferralCredentials != nil && *ldapConfig.ReferralCredentials == ReferralCredentialsReuse
+	if reuse && r.Scheme != "ldaps" {
+		logger.Warn("ldap_referral.connectReferral", "address", address, "anonymous_bind", "credentials are only reused over ldaps")
+		reuse = false
+	}
+	if reuse && !isTrustedReferralHost(ctx, d, r.Host) {
+		logger.Warn("ldap_referral.connectReferral", "address", address, "anonymous_bind", "credentials are only reused for hosts of the configured domains")
+		reuse = false
+	}
+
+	var ldapConn *ldap.Conn
+	var err error
+
+	if r.Scheme == "ldaps" {
+		ldapConn, err = ldap.DialURL("ldaps://"+address, ldap.DialWithTLSConfig(&tls.Config{ServerName: r.Host, InsecureSkipVerify: !reuse}))
+	} else {
+		ldapConn, err = ldap.DialURL("ldap://" + address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if reuse {
+		var username, password string
+		if ldapConfig.Username != nil {
+			username = *ldapConfig.Username
+		}
+		if ldapConfig.Password != nil {
+			password = *ldapConfig.Password
+		}
+		if err := ldapConn.Bind(username, password); err != nil {
+			ldapConn.Close()
+			return nil, err
+		}
+	}
+
+	logger.Debug("ldap_referral.connectReferral", "address", address, "reuse_credentials", reuse)
+
+	d.ConnectionManager.Cache.Set(cacheKey, ldapConn)
+
+	return ldapConn, nil
+}
+
+// isTrustedReferralHost returns whether a host belongs to the configured domains, i.e. is the configured host,
+// or lies in the DNS domain of a configured base DN or, in forest mode, of a domain of the forest
+func isTrustedReferralHost(ctx context.Context, d *plugin.QueryData, host string) bool {
+	ldapConfig := GetConfig(d.Connection)
+	if ldapConfig.Host != nil && strings.EqualFold(*ldapConfig.Host, host) {
+		return true
+	}
+
+	baseDNs := ldapConfig.baseDNs("")
+	for _, tableBaseDNs := range ldapConfig.TableBaseDNs {
+		baseDNs = append(baseDNs, tableBaseDNs...)
+	}
+	var domains []string
+	for _, baseDN := range baseDNs {
+		domains = append(domains, domainFromDN(baseDN))
+	}
+	if isForestMode(d) {
+		forestDomains, err := getForestDomains(ctx, d)
+		if err != nil {
+			plugin.Logger(ctx).Warn("ldap_referral.isTrustedReferralHost", "forest_domains_error", err)
+		}
+		for _, domain := range forestDomains {
+			domains = append(domains, domain.DNSName)
+		}
+	}
+
+	return isHostInDomains(host, domains)
+}
+
+// isHostInDomains returns whether a host is one of the DNS domains or a host within them
+func isHostInDomains(host string, domains []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorReferrals returns the referral URLs of a search which failed with a referral result code
+func errorReferrals(err error) []string {
+	ldapErr, ok := err.(*ldap.Error)
+	if !ok || ldapErr.ResultCode != ldap.LDAPResultReferral || ldapErr.Packet == nil || len(ldapErr.Packet.Children) < 2 {
+		return nil
+	}
+
+	var referrals []string
+	for _, child := range ldapErr.Packet.Children[1].Children {
+		// The referral of an LDAPResult is the optional element with context specific tag 3
+		if child.ClassType != ber.ClassContext || child.Tag != 3 {
+			continue
+		}
+		for _, uri := range child.Children {
+			if value, ok := uri.Value.(string); ok {
+				referrals = append(referrals, value)
+			}
+		}
+	}
+	return referrals
+}
@@ -0,0 +1,25 @@
+package ldap
+
+import "testing"
+
+func TestIsHostInDomains(t *testing.T) {
+	domains := []string{"example.com", "corp.example.org."}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{host: "example.com", want: true},
+		{host: "dc1.example.com", want: true},
+		{host: "DC2.Corp.Example.org", want: true},
+		{host: "example.org", want: false},
+		{host: "evilexample.com", want: false},
+		{host: "example.com.attacker.net", want: false},
+	}
+
+	for _, test := range tests {
+		if got := isHostInDomains(test.host, domains); got != test.want {
+			t.Errorf("isHostInDomains(%q) = %v, want %v", test.host, got, test.want)
+		}
+	}
+}
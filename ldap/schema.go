@@ -0,0 +1,383 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/iancoleman/strcase"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// Map containing LDAP attribute syntax OIDs to the custom column type used for their values.
+// Attributes with other syntaxes are returned as strings.
+// Refer - https://datatracker.ietf.org/doc/html/rfc4517#section-3.3
+var schemaSyntaxTypes = map[string]string{
+	"1.3.6.1.4.1.1466.115.121.1.7":  "bool",
+	"1.3.6.1.4.1.1466.115.121.1.24": "timestamp",
+	"1.3.6.1.4.1.1466.115.121.1.27": "int",
+	// Active Directory large integer
+	"1.2.840.113556.1.4.906": "int",
+}
+
+// Binary syntaxes, whose values can't be represented in a column
+var schemaBinarySyntaxes = map[string]bool{
+	"1.3.6.1.4.1.1466.115.121.1.4":  true, // Audio
+	"1.3.6.1.4.1.1466.115.121.1.5":  true, // Binary
+	"1.3.6.1.4.1.1466.115.121.1.8":  true, // Certificate
+	"1.3.6.1.4.1.1466.115.121.1.9":  true, // Certificate List
+	"1.3.6.1.4.1.1466.115.121.1.10": true, // Certificate Pair
+	"1.3.6.1.4.1.1466.115.121.1.28": true, // JPEG
+	"1.3.6.1.4.1.1466.115.121.1.40": true, // Octet String
+	"1.2.840.113556.1.4.907":        true, // Active Directory security descriptor
+}
+
+// Flags of object class and attribute type descriptions, which have no value
+var schemaFlags = map[string]bool{
+	"OBSOLETE":             true,
+	"SINGLE-VALUE":         true,
+	"COLLECTIVE":           true,
+	"NO-USER-MODIFICATION": true,
+	"ABSTRACT":             true,
+	"STRUCTURAL":           true,
+	"AUXILIARY":            true,
+}
+
+// schemaDefinition is an object class or attribute type description of the subschema (RFC 4512 section 4.1),
+// as a map of keyword to values, e.g. NAME, DESC, SUP, MUST, MAY or SYNTAX. Flags map to an empty slice.
+type schemaDefinition map[string][]string
+
+func (s schemaDefinition) name() string {
+	if len(s["NAME"]) > 0 {
+		return s["NAME"][0]
+	}
+	return ""
+}
+
+func (s schemaDefinition) description() string {
+	if len(s["DESC"]) > 0 {
+		return s["DESC"][0]
+	}
+	return ""
+}
+
+func (s schemaDefinition) has(keyword string) bool {
+	_, ok := s[keyword]
+	return ok
+}
+
+// directorySchema holds the object classes and attribute types of a server, keyed by lower case name
+type directorySchema struct {
+	ObjectClasses  map[string]schemaDefinition
+	AttributeTypes map[string]schemaDefinition
+}
+
+// schemaTableDefinitions returns the tables generated from the structural object classes of the directory schema
+// which are selected by the schema_tables and schema_tables_exclude arguments of the connection config.
+// When the schema can't be read, the error is logged and no table is returned
+func schemaTableDefinitions(ctx context.Context, ldapConfig ldapConfig) []customTableConfig {
+	if len(ldapConfig.SchemaTables) == 0 {
+		return nil
+	}
+
+	// An unreachable server or a subschema that can't be read must not prevent the built-in tables from loading
+	schema, err := readDirectorySchema(ctx, ldapConfig)
+	if err != nil {
+		plugin.Logger(ctx).Error("ldap_schema.schemaTableDefinitions", "schema_error", err, "skipping", "schema tables")
+		return nil
+	}
+
+	var tableConfigs []customTableConfig
+
+	for _, key := range sortedKeys(schema.ObjectClasses) {
+		objectClass := schema.ObjectClasses[key]
+		className := objectClass.name()
+
+		// Definitions are keyed by each of their names, so only consider the primary one.
+		// Object classes are structural unless declared abstract or auxiliary
+		if key != strings.ToLower(className) || objectClass.has("ABSTRACT") || objectClass.has("AUXILIARY") || objectClass.has("OBSOLETE") {
+			continue
+		}
+		if !matchesAnyPattern(ldapConfig.SchemaTables, className) || matchesAnyPattern(ldapConfig.SchemaTablesExclude, className) {
+			continue
+		}
+
+		tableConfigs = append(tableConfigs, schema.tableConfig(ctx, className))
+	}
+
+	return tableConfigs
+}
+
+// tableConfig builds the definition of the table for an object class, with one column per MUST or MAY attribute
+// of the class and its superclasses
+func (s *directorySchema) tableConfig(ctx context.Context, className string) customTableConfig {
+	objectClass := s.ObjectClasses[strings.ToLower(className)]
+
+	tableConfig := customTableConfig{
+		Name:         "ldap_" + strcase.ToSnake(className),
+		ObjectFilter: fmt.Sprintf("(objectClass=%s)", ldap.EscapeFilter(className)),
+	}
+	description := objectClass.description()
+	if description == "" {
+		description = fmt.Sprintf("Objects of the %s object class.", className)
+	}
+	tableConfig.Description = &description
+
+	columnNames := map[string]bool{}
+	for _, attributeName := range s.classAttributes(className) {
+		attributeType, ok := s.AttributeTypes[strings.ToLower(attributeName)]
+		if !ok {
+			plugin.Logger(ctx).Warn("ldap_schema.tableConfig", "unknown_attribute_type", attributeName, "object_class", className)
+			continue
+		}
+
+		syntax := s.attributeSyntax(attributeType)
+		if schemaBinarySyntaxes[syntax] {
+			continue
+		}
+
+		// objectClass and the USN attributes back standard columns already, while attributes named after other
+		// standard columns are prefixed, e.g. an attribute named depth is the attr_depth column
+		columnName := schemaColumnName(attributeName)
+		if columnName == "object_class" || containsEqualFold(customTableUSNColumns, columnName) {
+			continue
+		}
+		if containsEqualFold(customTableStandardColumns, columnName) {
+			plugin.Logger(ctx).Warn("ldap_schema.tableConfig", "standard_column_collision", attributeName, "object_class", className, "column", "attr_"+columnName)
+			columnName = "attr_" + columnName
+		}
+		if columnNames[columnName] {
+			continue
+		}
+		columnNames[columnName] = true
+
+		columnType, ok := schemaSyntaxTypes[syntax]
+		if !ok {
+			columnType = "string"
+		}
+		if !attributeType.has("SINGLE-VALUE") {
+			columnType = "json"
+		}
+
+		attribute := attributeName
+		column := customColumnConfig{
+			Name:      columnName,
+			Attribute: &attribute,
+			Type:      &columnType,
+		}
+		if attributeDescription := attributeType.description(); attributeDescription != "" {
+			column.Description = &attributeDescription
+		}
+		tableConfig.Columns = append(tableConfig.Columns, column)
+	}
+
+	return tableConfig
+}
+
+// schemaColumnName returns the name of the column for an attribute. Attributes backing the built-in columns
+// with a different name are named the same way, e.g. uSNChanged -> usn_changed rather than u_sn_changed
+func schemaColumnName(attributeName string) string {
+	for column, attribute := range ldapDisplayNames {
+		if strings.EqualFold(attribute, attributeName) {
+			return column
+		}
+	}
+	return strcase.ToSnake(attributeName)
+}
+
+// classAttributes returns the MUST and MAY attributes of an object class, including the ones inherited from its superclasses
+func (s *directorySchema) classAttributes(className string) []string {
+	var attributes []string
+	visited := map[string]bool{}
+
+	var collect func(name string)
+	collect = func(name string) {
+		key := strings.ToLower(name)
+		objectClass, ok := s.ObjectClasses[key]
+		if !ok || visited[key] {
+			return
+		}
+		visited[key] = true
+
+		attributes = append(attributes, objectClass["MUST"]...)
+		attributes = append(attributes, objectClass["MAY"]...)
+		for _, superclass := range objectClass["SUP"] {
+			collect(superclass)
+		}
+	}
+	collect(className)
+
+	return attributes
+}
+
+// attributeSyntax returns the syntax OID of an attribute type, which may be inherited from its supertype
+func (s *directorySchema) attributeSyntax(attributeType schemaDefinition) string {
+	visited := map[string]bool{}
+	for attributeType != nil {
+		if len(attributeType["SYNTAX"]) > 0 {
+			// Drop the optional length bound, e.g. 1.3.6.1.4.1.1466.115.121.1.15{256}
+			return strings.SplitN(attributeType["SYNTAX"][0], "{", 2)[0]
+		}
+		if len(attributeType["SUP"]) == 0 || visited[strings.ToLower(attributeType["SUP"][0])] {
+			break
+		}
+		visited[strings.ToLower(attributeType["SUP"][0])] = true
+		attributeType = s.AttributeTypes[strings.ToLower(attributeType["SUP"][0])]
+	}
+	return ""
+}
+
+// readDirectorySchema reads the object classes and attribute types from the subschema subentry advertised in the root DSE
+func readDirectorySchema(ctx context.Context, ldapConfig ldapConfig) (*directorySchema, error) {
+	logger := plugin.Logger(ctx)
+
+	conn, err := dial(ldapConfig)
+	if err != nil {
+		logger.Error("ldap_schema.readDirectorySchema", "connection_error", err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	rootDSEReq := ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"subschemaSubentry"}, []ldap.Control{})
+	rootDSE, err := conn.Search(rootDSEReq)
+	if err != nil {
+		logger.Error("ldap_schema.readDirectorySchema", "search_error", err)
+		return nil, err
+	}
+
+	subschemaDN := "cn=Subschema"
+	if len(rootDSE.Entries) > 0 && rootDSE.Entries[0].GetEqualFoldAttributeValue("subschemaSubentry") != "" {
+		subschemaDN = rootDSE.Entries[0].GetEqualFoldAttributeValue("subschemaSubentry")
+	}
+
+	logger.Debug("ldap_schema.readDirectorySchema", "subschemaSubentry", subschemaDN)
+
+	schemaReq := ldap.NewSearchRequest(subschemaDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=subschema)", []string{"objectClasses", "attributeTypes"}, []ldap.Control{})
+	result, err := conn.Search(schemaReq)
+	if err != nil {
+		logger.Error("ldap_schema.readDirectorySchema", "search_error", err)
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, fmt.Errorf("subschema subentry %q not found", subschemaDN)
+	}
+
+	schema := &directorySchema{
+		ObjectClasses:  parseSchemaDefinitions(ctx, result.Entries[0].GetEqualFoldAttributeValues("objectClasses")),
+		AttributeTypes: parseSchemaDefinitions(ctx, result.Entries[0].GetEqualFoldAttributeValues("attributeTypes")),
+	}
+
+	logger.Debug("ldap_schema.readDirectorySchema", "object_classes", len(schema.ObjectClasses), "attribute_types", len(schema.AttributeTypes))
+
+	return schema, nil
+}
+
+// parseSchemaDefinitions parses object class or attribute type descriptions, keyed by each of their lower case names
+func parseSchemaDefinitions(ctx context.Context, values []string) map[string]schemaDefinition {
+	definitions := map[string]schemaDefinition{}
+	for _, value := range values {
+		definition, err := parseSchemaDefinition(value)
+		if err != nil {
+			plugin.Logger(ctx).Warn("ldap_schema.parseSchemaDefinitions", "parse_error", err, "value", value)
+			continue
+		}
+		for _, name := range definition["NAME"] {
+			definitions[strings.ToLower(name)] = definition
+		}
+	}
+	return definitions
+}
+
+// parseSchemaDefinition parses a description like
+// ( 2.5.6.6 NAME 'person' SUP top STRUCTURAL MUST ( sn $ cn ) MAY ( userPassword $ telephoneNumber ) )
+// Refer - https://datatracker.ietf.org/doc/html/rfc4512#section-4.1
+func parseSchemaDefinition(value string) (schemaDefinition, error) {
+	tokens, err := tokenizeSchemaDefinition(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) < 3 || tokens[0] != "(" || tokens[len(tokens)-1] != ")" {
+		return nil, fmt.Errorf("invalid schema definition")
+	}
+
+	definition := schemaDefinition{"OID": {tokens[1]}}
+
+	// Skip the enclosing parentheses and the OID
+	tokens = tokens[2 : len(tokens)-1]
+	for i := 0; i < len(tokens); i++ {
+		keyword := tokens[i]
+		if schemaFlags[keyword] {
+			definition[keyword] = []string{}
+			continue
+		}
+		if i+1 >= len(tokens) {
+			return nil, fmt.Errorf("missing value for %s", keyword)
+		}
+		i++
+		if tokens[i] != "(" {
+			definition[keyword] = []string{tokens[i]}
+			continue
+		}
+		// A list of values, separated by $ for OIDs and by spaces for names
+		values := []string{}
+		for i++; i < len(tokens) && tokens[i] != ")"; i++ {
+			if tokens[i] != "$" {
+				values = append(values, tokens[i])
+			}
+		}
+		definition[keyword] = values
+	}
+
+	return definition, nil
+}
+
+// tokenizeSchemaDefinition splits a description into parentheses, dollar signs, quoted strings and words
+func tokenizeSchemaDefinition(value string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(value); {
+		switch c := value[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '$':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '\'':
+			end := strings.IndexByte(value[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted string")
+			}
+			tokens = append(tokens, value[i+1:i+1+end])
+			i += end + 2
+		default:
+			end := strings.IndexAny(value[i:], " \t\n\r()$'")
+			if end < 0 {
+				end = len(value) - i
+			}
+			tokens = append(tokens, value[i:i+end])
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// matchesAnyPattern returns whether a name matches any of the glob patterns, ignoring case
+func matchesAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(strings.ToLower(pattern), strings.ToLower(name)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(definitions map[string]schemaDefinition) []string {
+	keys := make([]string, 0, len(definitions))
+	for key := range definitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,120 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSchemaDefinition(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    schemaDefinition
+		wantErr bool
+	}{
+		{
+			name:  "object class",
+			value: "( 2.5.6.6 NAME 'person' DESC 'RFC2256: a person' SUP top STRUCTURAL MUST ( sn $ cn ) MAY ( userPassword $ telephoneNumber ) )",
+			want: schemaDefinition{
+				"OID":        {"2.5.6.6"},
+				"NAME":       {"person"},
+				"DESC":       {"RFC2256: a person"},
+				"SUP":        {"top"},
+				"STRUCTURAL": {},
+				"MUST":       {"sn", "cn"},
+				"MAY":        {"userPassword", "telephoneNumber"},
+			},
+		},
+		{
+			name:  "attribute type with several names and a length bound",
+			value: "( 2.5.4.3 NAME ( 'cn' 'commonName' ) SUP name SYNTAX 1.3.6.1.4.1.1466.115.121.1.15{64} SINGLE-VALUE )",
+			want: schemaDefinition{
+				"OID":          {"2.5.4.3"},
+				"NAME":         {"cn", "commonName"},
+				"SUP":          {"name"},
+				"SYNTAX":       {"1.3.6.1.4.1.1466.115.121.1.15{64}"},
+				"SINGLE-VALUE": {},
+			},
+		},
+		{
+			name:  "without spaces around parentheses",
+			value: "(1.2.3 NAME 'a' MAY (b$c))",
+			want: schemaDefinition{
+				"OID":  {"1.2.3"},
+				"NAME": {"a"},
+				"MAY":  {"b", "c"},
+			},
+		},
+		{
+			name:    "unterminated quoted string",
+			value:   "( 1.2.3 NAME 'a )",
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			value:   "( 1.2.3 NAME )",
+			wantErr: true,
+		},
+		{
+			name:    "not enclosed in parentheses",
+			value:   "1.2.3 NAME 'a'",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseSchemaDefinition(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseSchemaDefinition() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseSchemaDefinition() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSchemaTableConfig(t *testing.T) {
+	ctx := testContext()
+	schema := &directorySchema{
+		ObjectClasses: parseSchemaDefinitions(ctx, []string{
+			"( 2.5.6.0 NAME 'top' ABSTRACT MUST objectClass MAY uSNChanged )",
+			"( 2.5.6.6 NAME 'person' SUP top STRUCTURAL MUST ( sn $ cn ) MAY ( jpegPhoto $ telephoneNumber $ title $ employeeNumber $ depth ) )",
+		}),
+		AttributeTypes: parseSchemaDefinitions(ctx, []string{
+			"( 2.5.4.0 NAME 'objectClass' SYNTAX 1.3.6.1.4.1.1466.115.121.1.38 )",
+			"( 1.2.840.113556.1.2.120 NAME 'uSNChanged' SYNTAX 1.2.840.113556.1.4.906 SINGLE-VALUE )",
+			"( 2.5.4.41 NAME 'name' SYNTAX 1.3.6.1.4.1.1466.115.121.1.15 )",
+			"( 2.5.4.4 NAME ( 'sn' 'surname' ) SUP name SINGLE-VALUE )",
+			"( 2.5.4.3 NAME ( 'cn' 'commonName' ) SUP name )",
+			"( 0.9.2342.19200300.100.1.60 NAME 'jpegPhoto' SYNTAX 1.3.6.1.4.1.1466.115.121.1.28 )",
+			"( 2.5.4.20 NAME 'telephoneNumber' SYNTAX 1.3.6.1.4.1.1466.115.121.1.50 )",
+			"( 2.5.4.12 NAME 'title' SUP name SINGLE-VALUE )",
+			"( 2.16.840.1.113730.3.1.3 NAME 'employeeNumber' SYNTAX 1.3.6.1.4.1.1466.115.121.1.27 SINGLE-VALUE )",
+			"( 1.3.6.1.4.1.32473.1.1 NAME 'depth' SYNTAX 1.3.6.1.4.1.1466.115.121.1.27 SINGLE-VALUE )",
+		}),
+	}
+
+	tableConfig := schema.tableConfig(ctx, "person")
+	if tableConfig.Name != "ldap_person" || tableConfig.ObjectFilter != "(objectClass=person)" {
+		t.Fatalf("tableConfig() = %s %s, want ldap_person (objectClass=person)", tableConfig.Name, tableConfig.ObjectFilter)
+	}
+
+	got := map[string]string{}
+	for _, column := range tableConfig.Columns {
+		got[column.Name] = *column.Attribute + " " + column.typeName()
+	}
+	// objectClass and uSNChanged back standard columns, depth is named after one and jpegPhoto is binary
+	want := map[string]string{
+		"surname":          "sn string",
+		"cn":               "cn json",
+		"telephone_number": "telephoneNumber json",
+		"job_title":        "title string",
+		"employee_number":  "employeeNumber int",
+		"attr_depth":       "depth int",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tableConfig() columns = %v, want %v", got, want)
+	}
+}
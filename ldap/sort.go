@@ -0,0 +1,141 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// A sortKey is a column of the ORDER BY of a query, along with the attribute backing it
+type sortKey struct {
+	Column    string
+	Attribute string
+	Type      proto.ColumnType
+	Reverse   bool
+}
+
+// controlServerSideSort is the server side sort request control (RFC 2891). Unlike ldap.ControlServerSideSorting,
+// it is marked critical, so that a server unable to sort fails the search rather than returning unsorted entries,
+// and it omits the ordering rule, so that the server uses the ordering rule of each attribute
+type controlServerSideSort struct {
+	SortKeys []sortKey
+}
+
+func (c *controlServerSideSort) GetControlType() string {
+	return ldap.ControlTypeServerSideSorting
+}
+
+func (c *controlServerSideSort) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.GetControlType(), "Control Type"))
+	packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "Criticality"))
+
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value")
+	sortKeyList := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKeyList")
+	for _, key := range c.SortKeys {
+		sequence := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "SortKey")
+		sequence.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, key.Attribute, "attributeType"))
+		if key.Reverse {
+			sequence.AppendChild(ber.NewBoolean(ber.ClassContext, ber.TypePrimitive, 1, true, "reverseOrder"))
+		}
+		sortKeyList.AppendChild(sequence)
+	}
+	value.AppendChild(sortKeyList)
+	packet.AppendChild(value)
+
+	return packet
+}
+
+func (c *controlServerSideSort) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: true  SortKeys: %+v", ldap.ControlTypeMap[c.GetControlType()], c.GetControlType(), c.SortKeys)
+}
+
+// querySortKeys returns the sort keys for the ORDER BY of the query, which Steampipe only passes down
+// when all its columns are sortable, i.e. backed by an attribute of the profile
+func querySortKeys(d *plugin.QueryData, profile *directoryProfile) []sortKey {
+	columnTypes := map[string]proto.ColumnType{}
+	for _, column := range d.Table.Columns {
+		columnTypes[column.Name] = column.Type
+	}
+
+	var sortKeys []sortKey
+	for _, sortColumn := range d.QueryContext.SortOrder {
+		sortKeys = append(sortKeys, sortKey{
+			Column:    sortColumn.Column,
+			Attribute: profile.attributeName(d.Table.Name, sortColumn.Column),
+			Type:      columnTypes[sortColumn.Column],
+			Reverse:   sortColumn.Order == plugin.SortDesc,
+		})
+	}
+	return sortKeys
+}
+
+// supportsServerSideSort returns whether the root DSE advertises the server side sort control
+func supportsServerSideSort(ctx context.Context, d *plugin.QueryData) bool {
+	rootDSE, err := getRootDSE(ctx, d)
+	if err != nil {
+		plugin.Logger(ctx).Warn("ldap_sort.supportsServerSideSort", "root_dse_error", err)
+		return false
+	}
+	return containsEqualFold(rootDSE.GetEqualFoldAttributeValues("supportedControl"), ldap.ControlTypeServerSideSorting)
+}
+
+// sortEntries sorts entries in place, for searches the server cannot sort, e.g. searches under several base DNs.
+// Like servers do (RFC 2891 section 1.2), entries without a value for a sort key are ordered after all others
+func sortEntries(ctx context.Context, d *plugin.QueryData, profile *directoryProfile, sortKeys []sortKey, entries []*ldap.Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		for _, key := range sortKeys {
+			a := profile.attributeValue(d.Table.Name, key.Column, entries[i])
+			b := profile.attributeValue(d.Table.Name, key.Column, entries[j])
+
+			var result int
+			switch {
+			case a == b:
+				continue
+			case a == "":
+				return key.Reverse
+			case b == "":
+				return !key.Reverse
+			default:
+				result = compareAttributeValues(ctx, key.Type, a, b)
+			}
+
+			if result == 0 {
+				continue
+			}
+			if key.Reverse {
+				return result > 0
+			}
+			return result < 0
+		}
+		return false
+	})
+}
+
+// compareAttributeValues compares two values of an attribute according to the type of the column backed by it
+func compareAttributeValues(ctx context.Context, columnType proto.ColumnType, a string, b string) int {
+	switch columnType {
+	case proto.ColumnType_INT, proto.ColumnType_DOUBLE:
+		x, errX := strconv.ParseFloat(a, 64)
+		y, errY := strconv.ParseFloat(b, 64)
+		if errX == nil && errY == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case proto.ColumnType_TIMESTAMP:
+		return convertToTimestamp(ctx, a).Compare(*convertToTimestamp(ctx, b))
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
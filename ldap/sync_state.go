@@ -0,0 +1,128 @@
+package ldap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// Directory of the sync state files when sync_state_dir is not set, under the user cache directory
+const DefaultSyncStateDir = "steampipe-plugin-ldap"
+
+// Serializes the updates of the sync state files, which are read, modified and written back
+var syncStateMutex sync.Mutex
+
+// syncCookieKey returns the key of the cookie of a change table for a search, as a cookie only applies to the
+// base DN and filter it was returned for
+func syncCookieKey(table string, baseDN string, filter string) string {
+	return table + "|" + normalizeDN(baseDN) + "|" + filter
+}
+
+// syncStatePath returns the path of the file holding the cookies of the change tables of the connection,
+// i.e. <sync_state_dir>/<connection name>.json
+func syncStatePath(d *plugin.QueryData) (string, error) {
+	ldapConfig := GetConfig(d.Connection)
+	dir := ""
+	if ldapConfig.SyncStateDir != nil && *ldapConfig.SyncStateDir != "" {
+		dir = *ldapConfig.SyncStateDir
+	} else {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot locate the directory of the sync cookies, set sync_state_dir in the connection config: %v", err)
+		}
+		dir = filepath.Join(cacheDir, DefaultSyncStateDir)
+	}
+	return filepath.Join(dir, d.Connection.Name+".json"), nil
+}
+
+// readSyncState reads the cookies of the connection, keyed by syncCookieKey. A missing file holds no cookie
+func readSyncState(path string) (map[string][]byte, error) {
+	state := map[string][]byte{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid sync state file %s: %v", path, err)
+	}
+	return state, nil
+}
+
+// loadSyncCookie returns the cookie saved by the previous query of a change table, from the connection cache,
+// or else from the sync state file of the connection, so that it outlives the plugin process. It returns nil
+// if no cookie has been saved yet
+func loadSyncCookie(ctx context.Context, d *plugin.QueryData, key string) ([]byte, error) {
+	cacheKey := "ldap_sync_cookie_" + key
+	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
+		return cachedData.([]byte), nil
+	}
+
+	path, err := syncStatePath(d)
+	if err != nil {
+		return nil, err
+	}
+
+	syncStateMutex.Lock()
+	defer syncStateMutex.Unlock()
+
+	state, err := readSyncState(path)
+	if err != nil {
+		return nil, err
+	}
+
+	plugin.Logger(ctx).Debug("ldap_sync_state.loadSyncCookie", "path", path, "key", key, "found", state[key] != nil)
+
+	if cookie, ok := state[key]; ok {
+		d.ConnectionManager.Cache.Set(cacheKey, cookie)
+		return cookie, nil
+	}
+	return nil, nil
+}
+
+// saveSyncCookie saves the cookie returned at the end of a query of a change table, so that the next query
+// only returns the changes made since then
+func saveSyncCookie(ctx context.Context, d *plugin.QueryData, key string, cookie []byte) error {
+	path, err := syncStatePath(d)
+	if err != nil {
+		return err
+	}
+
+	syncStateMutex.Lock()
+	defer syncStateMutex.Unlock()
+
+	state, err := readSyncState(path)
+	if err != nil {
+		return err
+	}
+	state[key] = cookie
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("cannot save the sync cookie: %v", err)
+	}
+	// Write to a temporary file first, so that an interrupted write doesn't lose the other cookies
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("cannot save the sync cookie: %v", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("cannot save the sync cookie: %v", err)
+	}
+
+	plugin.Logger(ctx).Debug("ldap_sync_state.saveSyncCookie", "path", path, "key", key)
+
+	d.ConnectionManager.Cache.Set("ldap_sync_cookie_"+key, cookie)
+	return nil
+}
@@ -0,0 +1,324 @@
+package ldap
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// DirSync flags: return only the changed values of linked attributes such as member, and only the objects and
+// attributes the account can read, so that the Replicating Directory Changes right is not required
+const dirSyncFlags = ldap.DirSyncIncrementalValues | ldap.DirSyncObjectSecurity
+
+// Maximum number of values of an attribute returned by DirSync
+const dirSyncMaxAttrCount = 1000
+
+// Options of the linked attributes returned by DirSync with incremental values, e.g. member;range=1-1,
+// holding the values added to and removed from the attribute
+const (
+	linkedValuesAddedOption   = "range=1-1"
+	linkedValuesRemovedOption = "range=0-0"
+)
+
+// Attributes DirSync returns for every changed object, whether they changed or not
+var dirSyncBookkeepingAttributes = []string{"objectGUID", "instanceType"}
+
+type changeRow struct {
+	// Distinguished name
+	Dn string
+	// Base DN of the search
+	BaseDn string
+	// Filter of the search
+	Filter string
+	// Object GUID
+	ObjectGuid string
+	// Whether the object has been deleted
+	IsDeleted bool
+	// Names of the changed attributes
+	ChangedAttributes []string
+	// New values of the changed attributes
+	NewValues map[string][]string
+	// Values added to linked attributes
+	LinkedValuesAdded map[string][]string
+	// Values removed from linked attributes
+	LinkedValuesRemoved map[string][]string
+	// Cookie the change was read from
+	Cookie string
+	// Cookie to read the changes made after this one
+	NextCookie string
+}
+
+func tableLDAPChange(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_change",
+		Description: "The objects changed since the previous query of the table, read with the Active Directory DirSync control.",
+		List: &plugin.ListConfig{
+			Hydrate: listChanges,
+			KeyColumns: []*plugin.KeyColumn{
+				{Name: "base_dn", Require: plugin.Optional},
+				{Name: "filter", Require: plugin.Optional},
+				{Name: "cookie", Require: plugin.Optional},
+			},
+		},
+		Columns: commonColumns([]*plugin.Column{
+			// Top Columns
+			{
+				Name:        "dn",
+				Description: "Distinguished name of the changed object. Deleted objects have the DN of their tombstone in the Deleted Objects container.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "object_guid",
+				Description: "The globally unique identifier (GUID) of the changed object, which remains the same when the object is renamed, moved or deleted.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "is_deleted",
+				Description: "Whether the object has been deleted.",
+				Type:        proto.ColumnType_BOOL,
+			},
+
+			// Other Columns
+			{
+				Name:        "base_dn",
+				Description: "The Base DN on which the search was performed, which must be the root of a naming context.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "filter",
+				Description: "Optional search filter. Changes are tracked separately for each filter.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "cookie",
+				Description: "The DirSync cookie, in base64, from which the changes were read, i.e. the cookie saved by the previous query. Empty when all objects are read. Set it in a where clause to read the changes made after a given cookie, without updating the cookie saved for the connection.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "next_cookie",
+				Description: "The DirSync cookie, in base64, from which the changes made after the change are read.",
+				Type:        proto.ColumnType_STRING,
+			},
+
+			// JSON Columns
+			{
+				Name:        "changed_attributes",
+				Description: "Names of the attributes which have changed, including the linked attributes to which values have been added or from which values have been removed.",
+				Type:        proto.ColumnType_JSON,
+			},
+			{
+				Name:        "new_values",
+				Description: "New values of the changed attributes, except linked attributes.",
+				Type:        proto.ColumnType_JSON,
+			},
+			{
+				Name:        "linked_values_added",
+				Description: "Values added to the linked attributes of the object, e.g. the members added to a group, keyed by attribute.",
+				Type:        proto.ColumnType_JSON,
+			},
+			{
+				Name:        "linked_values_removed",
+				Description: "Values removed from the linked attributes of the object, e.g. the members removed from a group, keyed by attribute.",
+				Type:        proto.ColumnType_JSON,
+			},
+
+			// Steampipe Columns
+			{
+				Name:        "title",
+				Description: "Title of the changed object.",
+				Type:        proto.ColumnType_STRING,
+				Transform:   transform.FromField("Dn"),
+			},
+		}),
+	}
+}
+
+// listChanges reads the changes made under each base DN since the cookie saved by the previous query, or since
+// the cookie qual, in batches of changes, and saves the cookie returned with the last batch once all of them
+// have been read. Without a saved cookie, every object under the base DN is returned
+func listChanges(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_change.listChanges")
+
+	rootDSE, err := getRootDSE(ctx, d)
+	if err != nil {
+		logger.Error("ldap_change.listChanges", "root_dse_error", err)
+		return nil, err
+	}
+	if !containsEqualFold(rootDSE.GetEqualFoldAttributeValues("supportedControl"), ldap.ControlTypeDirSync) {
+		return nil, fmt.Errorf("table ldap_change requires the DirSync control (%s), which the server does not support", ldap.ControlTypeDirSync)
+	}
+
+	ldapConfig := GetConfig(d.Connection)
+	baseDNs, _, err := querySearchBases(ctx, d, ldapConfig.baseDNs(d.Table.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	filter := "(objectClass=*)"
+	if d.EqualsQuals["filter"] != nil {
+		filter = d.EqualsQualString("filter")
+		if !strings.HasPrefix(filter, "(") {
+			filter = "(" + filter
+		}
+		if !strings.HasSuffix(filter, ")") {
+			filter = filter + ")"
+		}
+	}
+
+	// A cookie qual reads the changes from that cookie, without touching the saved cookie
+	var qualCookie []byte
+	if d.EqualsQuals["cookie"] != nil {
+		qualCookie, err = base64.StdEncoding.DecodeString(d.EqualsQualString("cookie"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cookie %q, must be a cookie returned in the next_cookie column: %v", d.EqualsQualString("cookie"), err)
+		}
+	}
+
+	// Check all the base DNs before reading any changes, as reading them advances the saved cookies
+	for _, baseDN := range baseDNs {
+		if err := checkNamingContext(ctx, d, rootDSE, baseDN); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Debug("ldap_change.listChanges", "baseDNs", baseDNs, "filter", filter)
+
+	for _, baseDN := range baseDNs {
+		key := syncCookieKey(d.Table.Name, baseDN, filter)
+
+		cookie := qualCookie
+		if d.EqualsQuals["cookie"] == nil {
+			cookie, err = loadSyncCookie(ctx, d, key)
+			if err != nil {
+				logger.Error("ldap_change.listChanges", "sync_state_error", err)
+				return nil, err
+			}
+		}
+
+		cookie, complete, err := dirSync(ctx, d, baseDN, filter, ldapConfig.Attributes, cookie)
+		if err != nil {
+			logger.Error("ldap_change.listChanges", "search_error", err)
+			return nil, err
+		}
+
+		// Context can be cancelled due to manual cancellation or the limit has been hit, in which case the
+		// changes which have not been read are returned again by the next query
+		if !complete {
+			return nil, nil
+		}
+
+		if d.EqualsQuals["cookie"] == nil {
+			if err := saveSyncCookie(ctx, d, key, cookie); err != nil {
+				logger.Error("ldap_change.listChanges", "sync_state_error", err)
+				return nil, err
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// checkNamingContext returns an error unless the base DN is the root of a naming context listed in the root DSE,
+// or in forest mode of a domain of the forest, as DirSync searches must be based at one
+func checkNamingContext(ctx context.Context, d *plugin.QueryData, rootDSE *ldap.Entry, baseDN string) error {
+	namingContexts := rootDSE.GetEqualFoldAttributeValues("namingContexts")
+	if isForestMode(d) {
+		forestDNs, err := forestBaseDNs(ctx, d)
+		if err != nil {
+			return err
+		}
+		namingContexts = append(namingContexts, forestDNs...)
+	}
+
+	for _, namingContext := range namingContexts {
+		if normalizeDN(namingContext) == normalizeDN(baseDN) {
+			return nil
+		}
+	}
+	return fmt.Errorf("table ldap_change: base DN %q is not the root of a naming context, which DirSync searches must be based at. Set table_base_dns for ldap_change to one of %s", baseDN, strings.Join(namingContexts, ", "))
+}
+
+// dirSync streams the changes made under a base DN since a cookie, batch by batch, and returns the cookie
+// of the last batch read, along with whether all the batches have been read. The changes of a batch are
+// returned in a single response, and the cookie returned with a batch reads the changes after it
+func dirSync(ctx context.Context, d *plugin.QueryData, baseDN string, filter string, attributes []string, cookie []byte) ([]byte, bool, error) {
+	startCookie := base64.StdEncoding.EncodeToString(cookie)
+
+	for {
+		control := ldap.NewRequestControlDirSync(dirSyncFlags, dirSyncMaxAttrCount, cookie)
+		searchReq := ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, 0, 0, 0, false, filter, attributes, []ldap.Control{control})
+
+		result, err := search(ctx, d, searchReq)
+		if err != nil {
+			return nil, false, err
+		}
+
+		responseControl, ok := ldap.FindControl(result.Controls, ldap.ControlTypeDirSync).(*ldap.ControlDirSync)
+		if !ok {
+			return nil, false, fmt.Errorf("the server returned no DirSync cookie for %s", baseDN)
+		}
+		cookie = responseControl.Cookie
+		nextCookie := base64.StdEncoding.EncodeToString(cookie)
+
+		for _, entry := range result.Entries {
+			row := buildChangeRow(ctx, entry, baseDN)
+			row.Cookie, row.NextCookie = startCookie, nextCookie
+			if d.EqualsQuals["filter"] != nil {
+				row.Filter = d.EqualsQualString("filter")
+			}
+			d.StreamListItem(ctx, row)
+
+			if d.RowsRemaining(ctx) == 0 {
+				return cookie, false, nil
+			}
+		}
+
+		// The flags of the response are set when more changes remain to be read
+		if responseControl.Flags == 0 {
+			return cookie, true, nil
+		}
+	}
+}
+
+func buildChangeRow(ctx context.Context, entry *ldap.Entry, baseDN string) changeRow {
+	row := changeRow{
+		Dn:                  entry.DN,
+		BaseDn:              baseDN,
+		ObjectGuid:          getObjectGUID(entry),
+		IsDeleted:           strings.EqualFold(entry.GetEqualFoldAttributeValue("isDeleted"), "TRUE"),
+		ChangedAttributes:   []string{},
+		LinkedValuesAdded:   map[string][]string{},
+		LinkedValuesRemoved: map[string][]string{},
+	}
+
+	var values []*ldap.EntryAttribute
+	for _, attribute := range entry.Attributes {
+		if containsEqualFold(dirSyncBookkeepingAttributes, attribute.Name) {
+			continue
+		}
+
+		name, option, _ := strings.Cut(attribute.Name, ";")
+		switch strings.ToLower(option) {
+		case linkedValuesAddedOption:
+			row.LinkedValuesAdded[name] = append(row.LinkedValuesAdded[name], attribute.Values...)
+		case linkedValuesRemovedOption:
+			row.LinkedValuesRemoved[name] = append(row.LinkedValuesRemoved[name], attribute.Values...)
+		default:
+			values = append(values, &ldap.EntryAttribute{Name: name, Values: attribute.Values})
+		}
+
+		if !containsEqualFold(row.ChangedAttributes, name) {
+			row.ChangedAttributes = append(row.ChangedAttributes, name)
+		}
+	}
+	row.NewValues = transformAttributes(ctx, values)
+
+	return row
+}
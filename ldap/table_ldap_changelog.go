@@ -0,0 +1,536 @@
+package ldap
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Operations of the change log entries, as reported by the operation column
+const (
+	changelogOperationAdd    = "add"
+	changelogOperationModify = "modify"
+	changelogOperationDelete = "delete"
+	changelogOperationModRDN = "modrdn"
+)
+
+var changelogOperations = []string{changelogOperationAdd, changelogOperationModify, changelogOperationDelete, changelogOperationModRDN}
+
+// A changelogFormat describes where a type of directory logs the changes made to it, and how to read its entries
+type changelogFormat struct {
+	// Name of the log, for error messages
+	Name string
+	// Default DN of the log, unless table_base_dns sets one for ldap_changelog
+	BaseDN string
+	// Filter of the entries of successful changes
+	ObjectFilter string
+	// Attributes of the entries
+	Attributes []string
+	// Attribute holding the operation of an entry, i.e. one of changelogOperations
+	OperationAttribute string
+	// Filter of the entries logged after a position
+	PositionFilter func(position string) (string, error)
+	// Whether an entry is logged before another, by position
+	Before func(position string, other string) bool
+	// Builds the row of an entry
+	BuildRow func(ctx context.Context, entry *ldap.Entry) changelogRow
+}
+
+// The slapo-accesslog overlay of OpenLDAP, logging each write operation as an entry under cn=accesslog named
+// after the time the operation started, e.g. reqStart=20231018120000.000001Z,cn=accesslog
+var accesslogFormat = changelogFormat{
+	Name:               "accesslog",
+	BaseDN:             "cn=accesslog",
+	ObjectFilter:       "(&(|(objectClass=auditAdd)(objectClass=auditModify)(objectClass=auditDelete)(objectClass=auditModRDN))(reqResult=0))",
+	Attributes:         []string{"reqStart", "reqType", "reqDN", "reqMod", "reqAuthzID", "reqNewRDN", "reqDeleteOldRDN", "reqNewSuperior"},
+	OperationAttribute: "reqType",
+	PositionFilter: func(position string) (string, error) {
+		if _, err := time.Parse("20060102150405Z", position); err != nil {
+			return "", fmt.Errorf("invalid cookie %q, must be a reqStart value returned in the next_cookie column", position)
+		}
+		position = ldap.EscapeFilter(position)
+		return "(reqStart>=" + position + ")(!(reqStart=" + position + "))", nil
+	},
+	// reqStart values have microseconds, hence the same length
+	Before: func(position string, other string) bool {
+		return position < other
+	},
+	BuildRow: buildAccesslogRow,
+}
+
+// The retro changelog plugin of 389 Directory Server and FreeIPA, logging each change as an entry under
+// cn=changelog named after its change number, e.g. changenumber=42,cn=changelog
+var retroChangelogFormat = changelogFormat{
+	Name:               "retro changelog",
+	BaseDN:             "cn=changelog",
+	ObjectFilter:       "(objectClass=changeLogEntry)",
+	Attributes:         []string{"changeNumber", "changeType", "targetDn", "changes", "changeTime", "newRDN", "deleteOldRDN", "newSuperior"},
+	OperationAttribute: "changeType",
+	PositionFilter: func(position string) (string, error) {
+		changeNumber, err := strconv.ParseInt(position, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid cookie %q, must be a change number returned in the next_cookie column", position)
+		}
+		return "(changeNumber>=" + strconv.FormatInt(changeNumber+1, 10) + ")", nil
+	},
+	Before: func(position string, other string) bool {
+		a, _ := strconv.ParseInt(position, 10, 64)
+		b, _ := strconv.ParseInt(other, 10, 64)
+		return a < b
+	},
+	BuildRow: buildRetroChangelogRow,
+}
+
+// A changelogAttributeChange is a change made to an attribute, as reported by the changes column
+type changelogAttributeChange struct {
+	Attribute string   `json:"attribute"`
+	Operation string   `json:"operation"`
+	Values    []string `json:"values"`
+}
+
+type changelogRow struct {
+	// Distinguished name of the changed entry
+	Dn string
+	// Operation, i.e. add, modify, delete or modrdn
+	Operation string
+	// Time of the change
+	ChangeTime *time.Time
+	// Change number, for the retro changelog
+	ChangeNumber *int64
+	// DN of the identity which made the change, for the accesslog
+	Requester string
+	// New RDN of the entry, for modrdn
+	NewRdn string
+	// Whether the old RDN was removed from the entry, for modrdn
+	DeleteOldRdn *bool
+	// New parent of the entry, for modrdn
+	NewSuperior string
+	// Names of the changed attributes
+	ChangedAttributes []string
+	// Changes made to the attributes
+	Changes []changelogAttributeChange
+	// DN of the log
+	BaseDn string
+	// Position of the log the change was read from
+	Cookie string
+	// Position of the log to read the changes made after this one
+	NextCookie string
+}
+
+func tableLDAPChangelog(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_changelog",
+		Description: "The changes made since the previous query of the table, read from the accesslog of OpenLDAP or the retro changelog of 389 Directory Server and FreeIPA.",
+		List: &plugin.ListConfig{
+			Hydrate: listChangelog,
+			KeyColumns: []*plugin.KeyColumn{
+				{Name: "base_dn", Require: plugin.Optional},
+				{Name: "operation", Require: plugin.Optional},
+				{Name: "cookie", Require: plugin.Optional},
+			},
+		},
+		Columns: commonColumns([]*plugin.Column{
+			// Top Columns
+			{
+				Name:        "dn",
+				Description: "Distinguished name of the changed entry. Renamed entries have their DN before the change.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "operation",
+				Description: "The operation of the change, i.e. add, modify, delete or modrdn.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "change_time",
+				Description: "The time of the change.",
+				Type:        proto.ColumnType_TIMESTAMP,
+			},
+			{
+				Name:        "change_number",
+				Description: "The number of the change in the retro changelog. Empty for the OpenLDAP accesslog.",
+				Type:        proto.ColumnType_INT,
+			},
+			{
+				Name:        "requester",
+				Description: "Distinguished name of the identity which made the change. Empty for the retro changelog.",
+				Type:        proto.ColumnType_STRING,
+			},
+
+			// Other Columns
+			{
+				Name:        "new_rdn",
+				Description: "The new relative distinguished name of a renamed entry.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "delete_old_rdn",
+				Description: "Whether the old RDN value of a renamed entry was removed from the entry.",
+				Type:        proto.ColumnType_BOOL,
+			},
+			{
+				Name:        "new_superior",
+				Description: "Distinguished name of the new parent of a moved entry.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "base_dn",
+				Description: "Distinguished name of the log, cn=accesslog for OpenLDAP and cn=changelog for 389 Directory Server, unless table_base_dns sets another one for ldap_changelog.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "cookie",
+				Description: "The position of the log from which the changes were read, i.e. the cookie saved by the previous query. Empty when the whole log is read. Set it in a where clause to read the changes made after a given position, without updating the cookie saved for the connection.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "next_cookie",
+				Description: "The position of the change in the log, i.e. its reqStart for the accesslog and its change number for the retro changelog, from which the changes made after it are read.",
+				Type:        proto.ColumnType_STRING,
+			},
+
+			// JSON Columns
+			{
+				Name:        "changed_attributes",
+				Description: "Names of the attributes which have changed.",
+				Type:        proto.ColumnType_JSON,
+			},
+			{
+				Name:        "changes",
+				Description: "Changes made to the attributes of the entry, each with the attribute, the operation, i.e. add, delete, replace or increment, and the values. All the attributes of an added entry are reported as added.",
+				Type:        proto.ColumnType_JSON,
+			},
+
+			// Steampipe Columns
+			{
+				Name:        "title",
+				Description: "Title of the changed entry.",
+				Type:        proto.ColumnType_STRING,
+				Transform:   transform.FromField("Dn"),
+			},
+		}),
+	}
+}
+
+// getChangelogFormat returns the change log of the type of directory of the connection
+func getChangelogFormat(ctx context.Context, d *plugin.QueryData) (*changelogFormat, error) {
+	profile, err := getDirectoryProfile(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+
+	switch profile.Type {
+	case DirectoryTypeOpenLDAP:
+		return &accesslogFormat, nil
+	case DirectoryType389DS, DirectoryTypeFreeIPA:
+		return &retroChangelogFormat, nil
+	case DirectoryTypeActiveDirectory:
+		return nil, fmt.Errorf("table ldap_changelog does not support Active Directory, use the ldap_change table instead")
+	}
+	return nil, fmt.Errorf("table ldap_changelog does not support directory type %s", profile.Type)
+}
+
+// listChangelog reads the entries of the change log logged after the cookie saved by the previous query, or after
+// the cookie qual, and saves the greatest position read once all of them have been read. Without a saved cookie,
+// the whole log is read. The entries are streamed in the order the server returns them, i.e. the order they were
+// logged for the supported servers
+func listChangelog(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_changelog.listChangelog")
+
+	format, err := getChangelogFormat(ctx, d)
+	if err != nil {
+		logger.Error("ldap_changelog.listChangelog", "profile_error", err)
+		return nil, err
+	}
+
+	ldapConfig := GetConfig(d.Connection)
+	baseDNs := []string{format.BaseDN}
+	if tableBaseDNs, ok := ldapConfig.TableBaseDNs[d.Table.Name]; ok && len(tableBaseDNs) > 0 {
+		baseDNs = tableBaseDNs
+	}
+	baseDNs, _, err = querySearchBases(ctx, d, baseDNs)
+	if err != nil {
+		logger.Error("ldap_changelog.listChangelog", "base_dn_error", err)
+		return nil, err
+	}
+
+	filter := format.ObjectFilter
+	if d.EqualsQuals["operation"] != nil {
+		operation := d.EqualsQualString("operation")
+		if !containsEqualFold(changelogOperations, operation) {
+			return nil, nil
+		}
+		filter = "(&" + filter + "(" + format.OperationAttribute + "=" + strings.ToLower(operation) + "))"
+	}
+
+	logger.Debug("ldap_changelog.listChangelog", "log", format.Name, "baseDNs", baseDNs, "filter", filter)
+
+	for _, baseDN := range baseDNs {
+		key := syncCookieKey(d.Table.Name, baseDN, filter)
+
+		var cookie string
+		if d.EqualsQuals["cookie"] != nil {
+			cookie = d.EqualsQualString("cookie")
+		} else {
+			saved, err := loadSyncCookie(ctx, d, key)
+			if err != nil {
+				logger.Error("ldap_changelog.listChangelog", "sync_state_error", err)
+				return nil, err
+			}
+			cookie = string(saved)
+		}
+
+		position, complete, err := readChangelog(ctx, d, format, baseDN, filter, cookie)
+		if err != nil {
+			logger.Error("ldap_changelog.listChangelog", "search_error", err)
+			return nil, err
+		}
+
+		// Context can be cancelled due to manual cancellation or the limit has been hit, in which case the
+		// changes which have not been read are returned again by the next query
+		if !complete {
+			return nil, nil
+		}
+
+		if d.EqualsQuals["cookie"] == nil && position != cookie {
+			if err := saveSyncCookie(ctx, d, key, []byte(position)); err != nil {
+				logger.Error("ldap_changelog.listChangelog", "sync_state_error", err)
+				return nil, err
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// readChangelog streams the entries of a change log logged after a position and returns the greatest position read,
+// along with whether all of them have been read
+func readChangelog(ctx context.Context, d *plugin.QueryData, format *changelogFormat, baseDN string, filter string, cookie string) (string, bool, error) {
+	searchFilter := filter
+	if cookie != "" {
+		positionFilter, err := format.PositionFilter(cookie)
+		if err != nil {
+			return "", false, err
+		}
+		searchFilter = "(&" + filter + positionFilter + ")"
+	}
+
+	pageSize, err := configuredPageSize(d)
+	if err != nil {
+		return "", false, err
+	}
+
+	position := cookie
+	err = searchPaged(ctx, d, baseDN, ldap.ScopeSingleLevel, searchFilter, format.Attributes, nil, pageSize, func(entry *ldap.Entry) {
+		row := format.BuildRow(ctx, entry)
+		row.BaseDn, row.Cookie = baseDN, cookie
+		d.StreamListItem(ctx, row)
+
+		// The position is only saved once the whole log has been read, so it does not depend on the server order
+		if position == "" || format.Before(position, row.NextCookie) {
+			position = row.NextCookie
+		}
+	})
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return "", false, fmt.Errorf("the %s %s does not exist or cannot be read by the bind DN: %v", format.Name, baseDN, err)
+		}
+		return "", false, err
+	}
+
+	// Context can be cancelled due to manual cancellation or the limit has been hit
+	if d.RowsRemaining(ctx) == 0 {
+		return position, false, nil
+	}
+
+	return position, true, nil
+}
+
+// buildAccesslogRow builds the row of an accesslog entry, whose reqMod values each hold a change of a value,
+// i.e. "<attribute>:<operation> <value>", the operation being + (add), - (delete), = (replace) or # (increment).
+// Deleting or replacing all the values of an attribute has no value, e.g. "description:-"
+func buildAccesslogRow(ctx context.Context, entry *ldap.Entry) changelogRow {
+	row := changelogRow{
+		Dn:          entry.GetEqualFoldAttributeValue("reqDN"),
+		Operation:   strings.ToLower(entry.GetEqualFoldAttributeValue("reqType")),
+		Requester:   entry.GetEqualFoldAttributeValue("reqAuthzID"),
+		NewRdn:      entry.GetEqualFoldAttributeValue("reqNewRDN"),
+		NewSuperior: entry.GetEqualFoldAttributeValue("reqNewSuperior"),
+		NextCookie:  entry.GetEqualFoldAttributeValue("reqStart"),
+	}
+	if t := convertToTimestamp(ctx, row.NextCookie); !t.IsZero() {
+		row.ChangeTime = t
+	}
+	if value := entry.GetEqualFoldAttributeValue("reqDeleteOldRDN"); value != "" {
+		deleteOldRDN := strings.EqualFold(value, "TRUE")
+		row.DeleteOldRdn = &deleteOldRDN
+	}
+
+	var changes []changelogAttributeChange
+	for _, mod := range entry.GetEqualFoldAttributeValues("reqMod") {
+		attribute, rest, ok := strings.Cut(mod, ":")
+		if !ok || rest == "" {
+			continue
+		}
+
+		var operation string
+		switch rest[0] {
+		case '+':
+			operation = "add"
+		case '-':
+			operation = "delete"
+		case '=':
+			operation = "replace"
+		case '#':
+			operation = "increment"
+		default:
+			continue
+		}
+
+		var values []string
+		if value, ok := strings.CutPrefix(rest[1:], " "); ok {
+			values = []string{value}
+		}
+		changes = appendAttributeChange(changes, attribute, operation, values)
+	}
+	row.Changes, row.ChangedAttributes = changes, changedAttributeNames(changes)
+
+	return row
+}
+
+// buildRetroChangelogRow builds the row of a retro changelog entry, whose changes attribute holds the changes in
+// LDIF, i.e. the attributes of the added entry for an add, and "<operation>: <attribute>" blocks each followed by
+// the values and separated by "-" for a modify
+func buildRetroChangelogRow(ctx context.Context, entry *ldap.Entry) changelogRow {
+	row := changelogRow{
+		Dn:           entry.GetEqualFoldAttributeValue("targetDn"),
+		Operation:    strings.ToLower(entry.GetEqualFoldAttributeValue("changeType")),
+		ChangeNumber: convertToInt(ctx, entry.GetEqualFoldAttributeValue("changeNumber")),
+		NewRdn:       entry.GetEqualFoldAttributeValue("newRDN"),
+		NewSuperior:  entry.GetEqualFoldAttributeValue("newSuperior"),
+		NextCookie:   entry.GetEqualFoldAttributeValue("changeNumber"),
+	}
+	if t := convertToTimestamp(ctx, entry.GetEqualFoldAttributeValue("changeTime")); !t.IsZero() {
+		row.ChangeTime = t
+	}
+	if value := entry.GetEqualFoldAttributeValue("deleteOldRDN"); value != "" {
+		deleteOldRDN := strings.EqualFold(value, "TRUE")
+		row.DeleteOldRdn = &deleteOldRDN
+	}
+
+	changes := parseLDIFChanges(entry.GetEqualFoldAttributeValue("changes"), row.Operation == changelogOperationAdd)
+	row.Changes, row.ChangedAttributes = changes, changedAttributeNames(changes)
+
+	return row
+}
+
+// parseLDIFChanges parses the LDIF changes of a retro changelog entry. The attributes of an added entry are
+// reported as added values
+func parseLDIFChanges(ldif string, isAdd bool) []changelogAttributeChange {
+	var changes []changelogAttributeChange
+
+	// Unfold the lines continued on the next one, which starts with a space
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(ldif, "\x00"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.HasPrefix(line, " ") && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	// Attribute and operation of the current block of a modify
+	var attribute, operation string
+	for _, line := range lines {
+		if line == "-" {
+			// A block without values deletes or replaces all the values of the attribute
+			if attribute != "" && !containsAttributeChange(changes, attribute, operation) {
+				changes = appendAttributeChange(changes, attribute, operation, nil)
+			}
+			attribute, operation = "", ""
+			continue
+		}
+		if line == "" {
+			continue
+		}
+
+		name, value := parseLDIFLine(line)
+		if name == "" {
+			continue
+		}
+
+		switch {
+		case isAdd:
+			changes = appendAttributeChange(changes, name, "add", []string{value})
+		case attribute == "" && (strings.EqualFold(name, "add") || strings.EqualFold(name, "delete") || strings.EqualFold(name, "replace") || strings.EqualFold(name, "increment")):
+			attribute, operation = value, strings.ToLower(name)
+		case attribute != "" && strings.EqualFold(name, attribute):
+			changes = appendAttributeChange(changes, attribute, operation, []string{value})
+		}
+	}
+	if attribute != "" && !containsAttributeChange(changes, attribute, operation) {
+		changes = appendAttributeChange(changes, attribute, operation, nil)
+	}
+
+	return changes
+}
+
+// parseLDIFLine returns the attribute and value of an LDIF line, i.e. "<attribute>: <value>", or
+// "<attribute>:: <value>" for a value in base64. Values given by URL, i.e. "<attribute>:< <url>", are not read
+func parseLDIFLine(line string) (string, string) {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return "", ""
+	}
+	switch {
+	case strings.HasPrefix(value, ":"):
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value[1:]))
+		if err != nil {
+			return name, strings.TrimSpace(value[1:])
+		}
+		return name, string(decoded)
+	case strings.HasPrefix(value, "<"):
+		return name, strings.TrimSpace(value[1:])
+	}
+	return name, strings.TrimPrefix(value, " ")
+}
+
+// appendAttributeChange adds the values of a change to the previous change if it is made to the same attribute
+// with the same operation, or else appends the change
+func appendAttributeChange(changes []changelogAttributeChange, attribute string, operation string, values []string) []changelogAttributeChange {
+	if last := len(changes) - 1; last >= 0 && strings.EqualFold(changes[last].Attribute, attribute) && changes[last].Operation == operation {
+		changes[last].Values = append(changes[last].Values, values...)
+		return changes
+	}
+	if values == nil {
+		values = []string{}
+	}
+	return append(changes, changelogAttributeChange{Attribute: attribute, Operation: operation, Values: values})
+}
+
+// containsAttributeChange returns whether the last change is made to an attribute with an operation
+func containsAttributeChange(changes []changelogAttributeChange, attribute string, operation string) bool {
+	last := len(changes) - 1
+	return last >= 0 && strings.EqualFold(changes[last].Attribute, attribute) && changes[last].Operation == operation
+}
+
+// changedAttributeNames returns the distinct attributes of changes, in the order they were changed
+func changedAttributeNames(changes []changelogAttributeChange) []string {
+	names := []string{}
+	for _, change := range changes {
+		if !containsEqualFold(names, change.Attribute) {
+			names = append(names, change.Attribute)
+		}
+	}
+	return names
+}
@@ -0,0 +1,146 @@
+package ldap
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestBuildAccesslogRow(t *testing.T) {
+	ctx := testContext()
+
+	tests := []struct {
+		name       string
+		attributes map[string][]string
+		want       changelogRow
+	}{
+		{
+			name: "modify",
+			attributes: map[string][]string{
+				"reqStart":   {"20231018120000.000001Z"},
+				"reqType":    {"modify"},
+				"reqDN":      {"uid=bob,ou=people,dc=example,dc=com"},
+				"reqAuthzID": {"cn=admin,dc=example,dc=com"},
+				"reqMod":     {"mail:+ bob@example.com", "mail:+ robert@example.com", "description:-", "loginShell:= /bin/zsh", "uidNumber:# 1"},
+			},
+			want: changelogRow{
+				Dn:                "uid=bob,ou=people,dc=example,dc=com",
+				Operation:         "modify",
+				Requester:         "cn=admin,dc=example,dc=com",
+				NextCookie:        "20231018120000.000001Z",
+				ChangedAttributes: []string{"mail", "description", "loginShell", "uidNumber"},
+				Changes: []changelogAttributeChange{
+					{Attribute: "mail", Operation: "add", Values: []string{"bob@example.com", "robert@example.com"}},
+					{Attribute: "description", Operation: "delete", Values: []string{}},
+					{Attribute: "loginShell", Operation: "replace", Values: []string{"/bin/zsh"}},
+					{Attribute: "uidNumber", Operation: "increment", Values: []string{"1"}},
+				},
+			},
+		},
+		{
+			name: "modrdn",
+			attributes: map[string][]string{
+				"reqStart":        {"20231018120000.000002Z"},
+				"reqType":         {"modrdn"},
+				"reqDN":           {"uid=bob,ou=people,dc=example,dc=com"},
+				"reqNewRDN":       {"uid=robert"},
+				"reqDeleteOldRDN": {"TRUE"},
+				"reqNewSuperior":  {"ou=staff,dc=example,dc=com"},
+			},
+			want: changelogRow{
+				Dn:                "uid=bob,ou=people,dc=example,dc=com",
+				Operation:         "modrdn",
+				NewRdn:            "uid=robert",
+				DeleteOldRdn:      boolPointer(true),
+				NewSuperior:       "ou=staff,dc=example,dc=com",
+				NextCookie:        "20231018120000.000002Z",
+				ChangedAttributes: []string{},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			row := buildAccesslogRow(ctx, ldap.NewEntry("reqStart="+test.attributes["reqStart"][0]+",cn=accesslog", test.attributes))
+			if row.ChangeTime == nil {
+				t.Errorf("buildAccesslogRow().ChangeTime = nil, want the time of reqStart")
+			}
+			row.ChangeTime = nil
+			if !reflect.DeepEqual(row, test.want) {
+				t.Errorf("buildAccesslogRow() = %+v, want %+v", row, test.want)
+			}
+		})
+	}
+}
+
+func TestBuildRetroChangelogRow(t *testing.T) {
+	ctx := testContext()
+	changeNumber := int64(42)
+
+	tests := []struct {
+		name       string
+		changeType string
+		changes    string
+		want       []changelogAttributeChange
+	}{
+		{
+			name:       "add",
+			changeType: "add",
+			changes:    "objectClass: top\nobjectClass: person\ncn: Bob\nsn: Smith\n",
+			want: []changelogAttributeChange{
+				{Attribute: "objectClass", Operation: "add", Values: []string{"top", "person"}},
+				{Attribute: "cn", Operation: "add", Values: []string{"Bob"}},
+				{Attribute: "sn", Operation: "add", Values: []string{"Smith"}},
+			},
+		},
+		{
+			name:       "modify",
+			changeType: "modify",
+			changes:    "replace: mail\nmail: bob@example.com\n-\ndelete: description\n-\nadd: member\nmember: uid=a,dc=example,dc=com\nmember: uid=b,dc=exa\n mple,dc=com\n-\n",
+			want: []changelogAttributeChange{
+				{Attribute: "mail", Operation: "replace", Values: []string{"bob@example.com"}},
+				{Attribute: "description", Operation: "delete", Values: []string{}},
+				{Attribute: "member", Operation: "add", Values: []string{"uid=a,dc=example,dc=com", "uid=b,dc=example,dc=com"}},
+			},
+		},
+		{
+			name:       "base64 value and trailing NUL",
+			changeType: "modify",
+			changes:    "replace: description\ndescription:: w6l0w6k=\n-\n\x00",
+			want: []changelogAttributeChange{
+				{Attribute: "description", Operation: "replace", Values: []string{"été"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := ldap.NewEntry("changenumber=42,cn=changelog", map[string][]string{
+				"changeNumber": {"42"},
+				"changeType":   {test.changeType},
+				"targetDn":     {"uid=bob,ou=people,dc=example,dc=com"},
+				"changeTime":   {"20231018120000Z"},
+				"changes":      {test.changes},
+			})
+
+			row := buildRetroChangelogRow(ctx, entry)
+			if row.Dn != "uid=bob,ou=people,dc=example,dc=com" || row.Operation != test.changeType || row.NextCookie != "42" {
+				t.Errorf("buildRetroChangelogRow() = %+v, want the DN, operation and change number of the entry", row)
+			}
+			if row.ChangeNumber == nil || *row.ChangeNumber != changeNumber {
+				t.Errorf("buildRetroChangelogRow().ChangeNumber = %v, want %d", row.ChangeNumber, changeNumber)
+			}
+			if row.ChangeTime == nil {
+				t.Errorf("buildRetroChangelogRow().ChangeTime = nil, want the time of changeTime")
+			}
+			if !reflect.DeepEqual(row.Changes, test.want) {
+				t.Errorf("buildRetroChangelogRow().Changes = %+v, want %+v", row.Changes, test.want)
+			}
+		})
+	}
+}
+
+func boolPointer(value bool) *bool {
+	return &value
+}
@@ -0,0 +1,337 @@
+package ldap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Map containing the supported column types of custom tables
+var customColumnTypes = map[string]proto.ColumnType{
+	"string":    proto.ColumnType_STRING,
+	"int":       proto.ColumnType_INT,
+	"double":    proto.ColumnType_DOUBLE,
+	"bool":      proto.ColumnType_BOOL,
+	"timestamp": proto.ColumnType_TIMESTAMP,
+	"json":      proto.ColumnType_JSON,
+}
+
+// Columns every custom table has, which cannot be declared in a column block
+var customTableStandardColumns = []string{"dn", "base_dn", "scope", "filter", "object_class", "attributes", "title", "host_name", "domain", "dn_components", "normalized_dn", "parent_dn", "depth"}
+
+// Columns every custom table has unless a column block declares a column of the same name
+var customTableUSNColumns = []string{"usn_created", "usn_changed"}
+
+// Descriptions of the USN columns added to custom tables
+var customTableUSNColumnDescriptions = map[string]string{
+	"usn_created": "The update sequence number (USN) assigned by the domain controller when the object was created. Only maintained by Active Directory.",
+	"usn_changed": "The update sequence number (USN) assigned by the domain controller when the object was last changed. Only maintained by Active Directory.",
+}
+
+// tableLDAPCustom builds the table declared by a table block of the connection config
+func tableLDAPCustom(ctx context.Context, tableConfig customTableConfig) (*plugin.Table, error) {
+	if tableConfig.ObjectFilter == "" {
+		return nil, fmt.Errorf("table %q: 'object_filter' must be set", tableConfig.Name)
+	}
+
+	description := fmt.Sprintf("Objects matching %s.", tableConfig.ObjectFilter)
+	if tableConfig.Description != nil {
+		description = *tableConfig.Description
+	}
+
+	keyColumns := []*plugin.KeyColumn{
+		{Name: "base_dn", Require: plugin.Optional},
+		{Name: "filter", Require: plugin.Optional},
+		{Name: "scope", Require: plugin.Optional},
+		{Name: "parent_dn", Require: plugin.Optional},
+	}
+	var columns []*plugin.Column
+
+	// Top Columns
+	columns = append(columns, &plugin.Column{
+		Name:        "dn",
+		Description: "Distinguished name of the object.",
+		Type:        proto.ColumnType_STRING,
+		Transform:   transform.FromField("dn"),
+	})
+
+	for _, columnConfig := range tableConfig.Columns {
+		if containsEqualFold(customTableStandardColumns, columnConfig.Name) {
+			return nil, fmt.Errorf("table %q: column %q is reserved", tableConfig.Name, columnConfig.Name)
+		}
+
+		columnType, err := columnConfig.columnType()
+		if err != nil {
+			return nil, fmt.Errorf("table %q: %v", tableConfig.Name, err)
+		}
+
+		columnDescription := fmt.Sprintf("The %s attribute of the object.", columnConfig.attribute())
+		if columnConfig.Description != nil {
+			columnDescription = *columnConfig.Description
+		}
+
+		column := &plugin.Column{
+			Name:        columnConfig.Name,
+			Description: columnDescription,
+			Type:        columnType,
+			Transform:   transform.FromField(columnConfig.Name),
+		}
+		columns = append(columns, column)
+
+		// Push down the quals on declared columns which can be expressed as an LDAP filter,
+		// and the ORDER BY on declared single valued columns
+		switch columnType {
+		case proto.ColumnType_STRING, proto.ColumnType_INT:
+			keyColumns = append(keyColumns, &plugin.KeyColumn{Name: columnConfig.Name, Require: plugin.Optional})
+		case proto.ColumnType_TIMESTAMP:
+			keyColumns = append(keyColumns, &plugin.KeyColumn{Name: columnConfig.Name, Operators: []string{">", ">=", "=", "<", "<="}, Require: plugin.Optional})
+		}
+		if columnType != proto.ColumnType_JSON && columnType != proto.ColumnType_BOOL {
+			column.Sort = plugin.SortAll
+		}
+	}
+
+	// Add the USN columns, unless declared in a column block
+	for _, columnName := range customTableUSNColumns {
+		if tableConfig.declares(columnName) {
+			continue
+		}
+		columns = append(columns, &plugin.Column{
+			Name:        columnName,
+			Description: customTableUSNColumnDescriptions[columnName],
+			Type:        proto.ColumnType_INT,
+			Sort:        plugin.SortAll,
+			Transform:   transform.FromField(columnName),
+		})
+		keyColumns = append(keyColumns, &plugin.KeyColumn{Name: columnName, Operators: []string{">", ">=", "=", "<", "<="}, Require: plugin.Optional})
+	}
+
+	columns = append(columns,
+		// Other Columns
+		&plugin.Column{
+			Name:        "base_dn",
+			Description: "The Base DN on which the search was performed.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromField("base_dn"),
+		},
+		&plugin.Column{
+			Name:        "scope",
+			Description: "The scope of the search, one of base, one or sub. Defaults to sub, i.e. the whole subtree of the base DN.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromQual("scope").Transform(defaultSearchScope),
+		},
+		&plugin.Column{
+			Name:        "filter",
+			Description: "Optional search filter.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromField("filter"),
+		},
+
+		// JSON Columns
+		&plugin.Column{
+			Name:        "object_class",
+			Description: "Object classes of the object.",
+			Type:        proto.ColumnType_JSON,
+			Transform:   transform.FromField("object_class"),
+		},
+		&plugin.Column{
+			Name:        "attributes",
+			Description: "All attributes that have been returned from LDAP.",
+			Type:        proto.ColumnType_JSON,
+			Transform:   transform.FromField("attributes"),
+		},
+
+		// Steampipe Columns
+		&plugin.Column{
+			Name:        "title",
+			Description: "Title of the object.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromField("title"),
+		},
+	)
+
+	return &plugin.Table{
+		Name:        tableConfig.Name,
+		Description: description,
+		Get: &plugin.GetConfig{
+			KeyColumns: plugin.SingleColumn("dn"),
+			Hydrate:    getCustomObject(tableConfig),
+		},
+		List: &plugin.ListConfig{
+			Hydrate:    listCustomObjects(tableConfig),
+			KeyColumns: keyColumns,
+		},
+		Columns: commonColumns(columns),
+	}, nil
+}
+
+func getCustomObject(tableConfig customTableConfig) plugin.HydrateFunc {
+	return func(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+		logger := plugin.Logger(ctx)
+		logger.Trace("ldap_custom.getCustomObject", "table", tableConfig.Name)
+
+		objectDN := d.EqualsQuals["dn"].GetStringValue()
+
+		ldapConfig := GetConfig(d.Connection)
+
+		searchReq := ldap.NewSearchRequest(objectDN, ldap.ScopeBaseObject, 0, 1, 0, false, tableConfig.ObjectFilter, tableConfig.requestAttributes(d, ldapConfig.Attributes), []ldap.Control{})
+
+		result, err := search(ctx, d, searchReq)
+		if err != nil {
+			logger.Error("ldap_custom.getCustomObject", "table", tableConfig.Name, "search_error", err)
+			return nil, err
+		}
+
+		if len(result.Entries) > 0 {
+			return buildCustomRow(ctx, tableConfig, result.Entries[0], ldapConfig.baseDNOf(d.Table.Name, result.Entries[0].DN)), nil
+		}
+
+		return nil, nil
+	}
+}
+
+func listCustomObjects(tableConfig customTableConfig) plugin.HydrateFunc {
+	return func(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+		logger := plugin.Logger(ctx)
+		logger.Trace("ldap_custom.listCustomObjects", "table", tableConfig.Name)
+
+		ldapConfig := GetConfig(d.Connection)
+		baseDNs := ldapConfig.baseDNs(d.Table.Name)
+
+		keyQuals := d.EqualsQuals
+
+		profile := tableConfig.profile()
+		filter := generateFilterString(d, tableConfig.ObjectFilter, profile)
+		attributes := tableConfig.requestAttributes(d, ldapConfig.Attributes)
+
+		logger.Debug("ldap_custom.listCustomObjects", "table", tableConfig.Name, "baseDNs", baseDNs)
+		logger.Debug("ldap_custom.listCustomObjects", "table", tableConfig.Name, "filter", filter)
+		logger.Debug("ldap_custom.listCustomObjects", "table", tableConfig.Name, "attributes", attributes)
+
+		err := searchBaseDNs(ctx, d, baseDNs, filter, attributes, profile, func(entry *ldap.Entry, baseDN string) {
+			row := buildCustomRow(ctx, tableConfig, entry, baseDN)
+
+			if keyQuals["filter"] != nil {
+				row["filter"] = keyQuals["filter"].GetStringValue()
+			}
+
+			d.StreamListItem(ctx, row)
+		})
+		if err != nil {
+			logger.Error("ldap_custom.listCustomObjects", "table", tableConfig.Name, "search_error", err)
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+// buildCustomRow converts an entry to a row keyed by column name
+func buildCustomRow(ctx context.Context, tableConfig customTableConfig, entry *ldap.Entry, baseDN string) map[string]interface{} {
+	row := map[string]interface{}{
+		"dn":           entry.DN,
+		"base_dn":      baseDN,
+		"object_class": entry.GetAttributeValues("objectClass"),
+		"attributes":   transformAttributes(ctx, entry.Attributes),
+		"title":        entry.GetAttributeValue("cn"),
+		"usn_created":  convertToInt(ctx, entry.GetEqualFoldAttributeValue("uSNCreated")),
+		"usn_changed":  convertToInt(ctx, entry.GetEqualFoldAttributeValue("uSNChanged")),
+	}
+	if row["title"] == "" {
+		row["title"] = entry.DN
+	}
+
+	for _, columnConfig := range tableConfig.Columns {
+		attribute := columnConfig.attribute()
+		value := entry.GetEqualFoldAttributeValue(attribute)
+
+		switch columnConfig.typeName() {
+		case "int":
+			row[columnConfig.Name] = convertToInt(ctx, value)
+		case "double":
+			if value != "" {
+				parsed, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					plugin.Logger(ctx).Error("ldap_custom.buildCustomRow", "Error while converting string to float", err)
+					continue
+				}
+				row[columnConfig.Name] = parsed
+			}
+		case "bool":
+			row[columnConfig.Name] = convertToBool(ctx, value)
+		case "timestamp":
+			if value != "" && !time.Time.IsZero(*convertToTimestamp(ctx, value)) {
+				row[columnConfig.Name] = convertToTimestamp(ctx, value)
+			}
+		case "json":
+			row[columnConfig.Name] = entry.GetEqualFoldAttributeValues(attribute)
+		default:
+			row[columnConfig.Name] = value
+		}
+	}
+
+	return row
+}
+
+// profile maps the declared columns to their attributes, so that quals and ORDER BY on them can be pushed down
+// declares returns whether a column block declares the column
+func (c customTableConfig) declares(columnName string) bool {
+	for _, columnConfig := range c.Columns {
+		if strings.EqualFold(columnConfig.Name, columnName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c customTableConfig) profile() *directoryProfile {
+	columnAttributes := map[string]string{}
+	for _, columnConfig := range c.Columns {
+		columnAttributes[columnConfig.Name] = columnConfig.attribute()
+	}
+	return &directoryProfile{
+		TableColumnAttributes: map[string]map[string]string{c.Name: columnAttributes},
+	}
+}
+
+// requestAttributes returns the attributes set in the connection config, or else those needed by the columns
+// of the query. When all attributes are requested, the declared ones are requested too, as they may be operational attributes
+func (c customTableConfig) requestAttributes(d *plugin.QueryData, configAttributes []string) []string {
+	if configAttributes != nil {
+		return configAttributes
+	}
+	var declared []string
+	for _, columnConfig := range c.Columns {
+		declared = append(declared, columnConfig.attribute())
+	}
+	return queryAttributes(d, c.profile(), map[string][]string{"title": {"cn"}}, declared...)
+}
+
+func (c customColumnConfig) attribute() string {
+	if c.Attribute != nil && *c.Attribute != "" {
+		return *c.Attribute
+	}
+	return c.Name
+}
+
+func (c customColumnConfig) typeName() string {
+	if c.Type != nil && *c.Type != "" {
+		return *c.Type
+	}
+	return "string"
+}
+
+func (c customColumnConfig) columnType() (proto.ColumnType, error) {
+	columnType, ok := customColumnTypes[c.typeName()]
+	if !ok {
+		return proto.ColumnType_UNKNOWN, fmt.Errorf("column %q: 'type' must be one of string, int, double, bool, timestamp or json, got %q", c.Name, c.typeName())
+	}
+	return columnType, nil
+}
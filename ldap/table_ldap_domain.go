@@ -0,0 +1,305 @@
+package ldap
+
+import (
+	"context"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// Map containing msDS-Behavior-Version values to the domain functional level they represent
+// Refer - https://docs.microsoft.com/en-us/openspecs/windows_protocols/ms-adts/d7422d35-448a-451a-8846-6a7def0044df
+var domainFunctionalLevels = map[int64]string{
+	0:  "Windows2000",
+	1:  "Windows2003Interim",
+	2:  "Windows2003",
+	3:  "Windows2008",
+	4:  "Windows2008R2",
+	5:  "Windows2012",
+	6:  "Windows2012R2",
+	7:  "Windows2016",
+	10: "Windows2025",
+}
+
+var domainTable = &mappedTable{
+	Name: "ldap_domain",
+	// Domain heads are domainDNS objects in Active Directory, and domain or dcObject entries in other directories,
+	// so that organizational units or containers set as base DN are not reported as domains
+	ObjectFilter: func(ldapConfig, *directoryProfile) string {
+		return "(|(objectClass=domain)(objectClass=domainDNS)(objectClass=dcObject))"
+	},
+	Columns: []*mappedColumn{
+		// Top Columns
+		{
+			Name:        "dn",
+			Description: "Distinguished name of the domain.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "name",
+			Description: "Name of the domain.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  domainNameAttributes,
+			Value:       domainNameValue,
+		},
+		{
+			Name:        "object_sid",
+			Description: "The security identifier (SID) of the domain.",
+			Type:        proto.ColumnType_STRING,
+			Value:       objectSidValue,
+		},
+		{
+			Name:        "min_pwd_length",
+			Description: "The minimum number of characters that a password must contain.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "min_pwd_age_seconds",
+			Description: "The minimum amount of time, in seconds, that a password must be used before it can be changed.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "minPwdAge",
+			Value:       intervalValue,
+		},
+		{
+			Name:        "max_pwd_age_seconds",
+			Description: "The maximum amount of time, in seconds, that a password can be used before it must be changed. Null if passwords never expire.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "maxPwdAge",
+			Value:       intervalValue,
+		},
+		{
+			Name:        "pwd_history_length",
+			Description: "The number of old passwords that are remembered and cannot be reused.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "lockout_threshold",
+			Description: "The number of failed logon attempts after which an account is locked out. 0 means accounts are never locked out.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "lockout_duration_seconds",
+			Description: "The amount of time, in seconds, that a locked out account remains locked. Null if accounts remain locked until an administrator unlocks them.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "lockoutDuration",
+			Value:       intervalValue,
+		},
+		{
+			Name:        "lockout_observation_window_seconds",
+			Description: "The amount of time, in seconds, after which the failed logon attempt counter is reset.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "lockOutObservationWindow",
+			Value:       intervalValue,
+		},
+		{
+			Name:        "password_complexity_enabled",
+			Description: "Whether passwords must meet complexity requirements.",
+			Type:        proto.ColumnType_BOOL,
+			Attributes:  columnAttributes("pwd_properties"),
+			Value:       passwordComplexityValue,
+		},
+		{
+			Name:        "machine_account_quota",
+			Description: "The number of computer accounts that a user is allowed to create in the domain.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "ms-DS-MachineAccountQuota",
+			Value:       intValue,
+		},
+		{
+			Name:        "domain_functional_level",
+			Description: "The domain functional level, as stored in msDS-Behavior-Version.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-Behavior-Version",
+			Value:       intValue,
+		},
+		{
+			Name:        "domain_functional_level_name",
+			Description: "The name of the domain functional level, e.g. Windows2016.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  columnAttributes("domain_functional_level"),
+			Value:       domainFunctionalLevelNameValue,
+		},
+		{
+			Name:        "when_created",
+			Description: "Date when the domain was created.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Value:       timestampValue,
+		},
+		{
+			Name:        "when_changed",
+			Description: "Date when the domain was last changed.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Value:       timestampValue,
+		},
+		{
+			Name:        "usn_created",
+			Description: "The update sequence number (USN) assigned by the domain controller when the domain was created. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "usn_changed",
+			Description: "The update sequence number (USN) assigned by the domain controller when the domain was last changed. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "highest_committed_usn",
+			Description: "The highest update sequence number (USN) committed by the domain controller, read from its root DSE when the query runs. Objects changed afterwards have a greater usn_changed on that domain controller.",
+			Type:        proto.ColumnType_INT,
+			Attributes:  noAttributes,
+			Value:       highestCommittedUSNValue,
+		},
+
+		// Other Columns
+		{
+			Name:        "pwd_properties",
+			Description: "The password properties bit flags of the domain.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "base_dn",
+			Description: "The Base DN on which the search was performed.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+
+		// JSON Columns
+		{
+			Name:        "object_class",
+			Description: "Object classes of the domain.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "attributes",
+			Description: "All attributes that have been returned from LDAP.",
+			Type:        proto.ColumnType_JSON,
+			Value:       attributesValue,
+		},
+
+		// Steampipe Columns
+		{
+			Name:        "title",
+			Description: "Title of the domain.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  domainNameAttributes,
+			Value:       domainNameValue,
+		},
+	},
+}
+
+func tableLDAPDomain(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_domain",
+		Description: "The domain head object, including the domain-wide password and account lockout policy.",
+		List: &plugin.ListConfig{
+			Hydrate:    listDomains,
+			KeyColumns: domainTable.keyColumns(),
+		},
+		Columns: domainTable.columns(),
+	}
+}
+
+func listDomains(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_domain.listDomains")
+
+	ldapConfig := GetConfig(d.Connection)
+	baseDNs, _, err := querySearchBases(ctx, d, ldapConfig.baseDNs(d.Table.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := domainTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_domain.listDomains", "profile_error", err)
+		return nil, err
+	}
+
+	filter := generateFilterString(d, domainTable.ObjectFilter(ldapConfig, profile), profile)
+
+	// If no attributes are passed in, search request will get those needed by the columns of the query
+	attributes := domainTable.requestAttributes(d, profile)
+
+	logger.Debug("ldap_domain.listDomains", "baseDNs", baseDNs)
+	logger.Debug("ldap_domain.listDomains", "filter", filter)
+	logger.Debug("ldap_domain.listDomains", "attributes", attributes)
+
+	// The domain head is the object at the base DN, so a base object search is sufficient
+	for _, baseDN := range baseDNs {
+		searchReq := ldap.NewSearchRequest(baseDN, ldap.ScopeBaseObject, 0, 1, 0, false, filter, attributes, []ldap.Control{})
+
+		result, err := search(ctx, d, searchReq)
+		if err != nil {
+			logger.Error("ldap_domain.listDomains", "search_error", err)
+			return nil, err
+		}
+
+		for _, entry := range result.Entries {
+			row := domainTable.buildRow(ctx, d, entry, baseDN, profile)
+			if containsEqualFold(d.QueryContext.Columns, "highest_committed_usn") {
+				row["highest_committed_usn"], err = getHighestCommittedUSN(ctx, d, entry.DN)
+				if err != nil {
+					logger.Error("ldap_domain.listDomains", "root_dse_error", err)
+					return nil, err
+				}
+			}
+			d.StreamListItem(ctx, row)
+		}
+
+		// Context can be cancelled due to manual cancellation or the limit has been hit
+		if d.RowsRemaining(ctx) == 0 {
+			return nil, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// domainNameAttributes backs the name of a domain by its name attribute, or else its domain component
+func domainNameAttributes(profile *directoryProfile, table string) []string {
+	return []string{profile.attributeName(table, "name"), "dc"}
+}
+
+// domainNameValue returns the name of a domain, or its domain component if it has no name, e.g. on OpenLDAP
+func domainNameValue(_ context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	for _, attribute := range attributes {
+		if value := entry.GetEqualFoldAttributeValue(attribute); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// passwordComplexityValue returns whether password complexity is enforced, i.e. whether the first bit of pwdProperties is set
+// Refer - https://docs.microsoft.com/en-us/windows/win32/adschema/a-pwdproperties
+func passwordComplexityValue(ctx context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	pwdProperties := convertToInt(ctx, entry.GetEqualFoldAttributeValue(attributes[0]))
+	if pwdProperties == nil {
+		return nil
+	}
+	complexityEnabled := *pwdProperties&1 == 1
+	return &complexityEnabled
+}
+
+// domainFunctionalLevelNameValue returns the name of the domain functional level stored in msDS-Behavior-Version
+func domainFunctionalLevelNameValue(ctx context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+	level := convertToInt(ctx, entry.GetEqualFoldAttributeValue(attributes[0]))
+	if level == nil {
+		return ""
+	}
+	return domainFunctionalLevels[*level]
+}
+
+// highestCommittedUSNValue leaves the column empty, as it is read from the root DSE rather than from the domain head
+func highestCommittedUSNValue(context.Context, *ldap.Entry, []string, *directoryProfile) interface{} {
+	return nil
+}
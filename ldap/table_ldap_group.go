@@ -2,7 +2,6 @@ package ldap
 
 import (
 	"context"
-	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
@@ -10,35 +9,145 @@ import (
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
 )
 
-type groupRow struct {
-	// Distinguished name
-	Dn string
-	// Base domain name
-	BaseDn string
-	// Filter string
-	Filter string
-	// Common name
-	Cn string
-	// Description
-	Description string
-	// Creation date
-	WhenCreated *time.Time
-	// Last modified date
-	WhenChanged *time.Time
-	// Object class
-	ObjectClass []string
-	// Organizational unit the group belongs to
-	Ou string
-	// Object SID
-	ObjectSid string
-	// SAM account name
-	SamAccountName string
-	// Title
-	Title string
-	// Groups the group belongs to
-	MemberOf []string
-	// All attributes that are configured to be returned
-	Attributes map[string][]string
+var groupTable = &mappedTable{
+	Name: "ldap_group",
+	ObjectFilter: func(ldapConfig ldapConfig, profile *directoryProfile) string {
+		return configuredFilter(ldapConfig.GroupObjectFilter, profile.GroupObjectFilter)
+	},
+	Columns: []*mappedColumn{
+		// Top Columns
+		{
+			Name:        "dn",
+			Description: "Distinguished name of the group.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "cn",
+			Description: "Common/Full name of the group.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "object_sid",
+			Description: "The security identifier (SID) of the group.",
+			Type:        proto.ColumnType_STRING,
+			Value:       objectSidValue,
+			Operators:   equalsOperators,
+			Get:         true,
+		},
+		{
+			Name:        "object_guid",
+			Description: "The globally unique identifier (GUID) of the group.",
+			Type:        proto.ColumnType_STRING,
+			Value:       objectGUIDValue,
+			Operators:   equalsOperators,
+			Get:         true,
+		},
+		{
+			Name:        "ou",
+			Description: "Organizational unit to which the group belongs to.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  noAttributes,
+			Value:       organizationalUnitValue,
+		},
+		{
+			Name:        "sam_account_name",
+			Description: "SAM Account name of the group.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+			Get:         true,
+		},
+		{
+			Name:        "when_created",
+			Description: "Date when the group was created.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Sort:        plugin.SortAll,
+			Value:       timestampValue,
+			Operators:   timestampOperators,
+		},
+		{
+			Name:        "when_changed",
+			Description: "Date when the group was last changed.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Sort:        plugin.SortAll,
+			Value:       timestampValue,
+			Operators:   timestampOperators,
+		},
+		{
+			Name:        "usn_created",
+			Description: "The update sequence number (USN) assigned by the domain controller when the group was created. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Sort:        plugin.SortAll,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "usn_changed",
+			Description: "The update sequence number (USN) assigned by the domain controller when the group was last changed. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Sort:        plugin.SortAll,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+
+		// Other Columns
+		{
+			Name:        "description",
+			Description: "Description of the group.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "base_dn",
+			Description: "The Base DN on which the search was performed.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "scope",
+			Description: "The scope of the search, one of base, one or sub. Defaults to sub, i.e. the whole subtree of the base DN.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromQual("scope").Transform(defaultSearchScope),
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "filter",
+			Description: "Optional search filter.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+
+		// JSON Columns
+		{
+			Name:        "member_of",
+			Description: "Groups that the group is a member of.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "object_class",
+			Description: "Object classes of the group.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "attributes",
+			Description: "All attributes that have been returned from LDAP.",
+			Type:        proto.ColumnType_JSON,
+			Value:       attributesValue,
+		},
+
+		// Steampipe Columns
+		{
+			Name:        "title",
+			Description: "Title of the group.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  columnAttributes("cn"),
+		},
+	},
 }
 
 func tableLDAPGroup(ctx context.Context) *plugin.Table {
@@ -46,101 +155,14 @@ func tableLDAPGroup(ctx context.Context) *plugin.Table {
 		Name:        "ldap_group",
 		Description: "A group is a collection of digital identities, e.g., users, groups.",
 		Get: &plugin.GetConfig{
-			KeyColumns: plugin.SingleColumn("dn"),
+			KeyColumns: groupTable.getKeyColumns(),
 			Hydrate:    getGroup,
 		},
 		List: &plugin.ListConfig{
-			Hydrate: listGroups,
-			KeyColumns: []*plugin.KeyColumn{
-				{Name: "cn", Require: plugin.Optional},
-				{Name: "description", Require: plugin.Optional},
-				{Name: "filter", Require: plugin.Optional},
-				{Name: "object_sid", Require: plugin.Optional},
-				{Name: "sam_account_name", Require: plugin.Optional},
-				{Name: "when_changed", Operators: []string{">", ">=", "=", "<", "<="}, Require: plugin.Optional},
-				{Name: "when_created", Operators: []string{">", ">=", "=", "<", "<="}, Require: plugin.Optional},
-			},
+			Hydrate:    listGroups,
+			KeyColumns: groupTable.keyColumns(),
 		},
-		Columns: commonColumns([]*plugin.Column{
-			// Top Columns
-			{
-				Name:        "dn",
-				Description: "Distinguished name of the group.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "cn",
-				Description: "Common/Full name of the group.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "object_sid",
-				Description: "The security identifier (SID) of the group.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "ou",
-				Description: "Organizational unit to which the group belongs to.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "sam_account_name",
-				Description: "SAM Account name of the group.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "when_created",
-				Description: "Date when the group was created.",
-				Type:        proto.ColumnType_TIMESTAMP,
-			},
-			{
-				Name:        "when_changed",
-				Description: "Date when the group was last changed.",
-				Type:        proto.ColumnType_TIMESTAMP,
-			},
-
-			// Other Columns
-			{
-				Name:        "description",
-				Description: "Description of the group.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "base_dn",
-				Description: "The Base DN on which the search was performed.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "filter",
-				Description: "Optional search filter.",
-				Type:        proto.ColumnType_STRING,
-			},
-
-			// JSON Columns
-			{
-				Name:        "member_of",
-				Description: "Groups that the group is a member of.",
-				Type:        proto.ColumnType_JSON,
-			},
-			{
-				Name:        "object_class",
-				Description: "Object classes of the group.",
-				Type:        proto.ColumnType_JSON,
-			},
-			{
-				Name:        "attributes",
-				Description: "All attributes that have been returned from LDAP.",
-				Type:        proto.ColumnType_JSON,
-			},
-
-			// Steampipe Columns
-			{
-				Name:        "title",
-				Description: "Title of the group.",
-				Type:        proto.ColumnType_STRING,
-				Transform:   transform.FromField("Cn"),
-			},
-		}),
+		Columns: groupTable.columns(),
 	}
 }
 
@@ -148,157 +170,49 @@ func getGroup(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (
 	logger := plugin.Logger(ctx)
 	logger.Trace("ldap_group.getGroup")
 
-	groupDN := d.EqualsQuals["dn"].GetStringValue()
-
-	ldapConfig := GetConfig(d.Connection)
-
-	var searchReq *ldap.SearchRequest
-
-	if ldapConfig.Attributes != nil {
-		searchReq = ldap.NewSearchRequest(groupDN, ldap.ScopeBaseObject, 0, 1, 0, false, "(&)", ldapConfig.Attributes, []ldap.Control{})
-	} else {
-		searchReq = ldap.NewSearchRequest(groupDN, ldap.ScopeBaseObject, 0, 1, 0, false, "(&)", []string{}, []ldap.Control{})
+	profile, err := groupTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_group.getGroup", "profile_error", err)
+		return nil, err
 	}
 
-	result, err := search(ctx, d, searchReq)
+	row, err := groupTable.getRow(ctx, d, profile)
 	if err != nil {
 		logger.Error("ldap_group.getGroup", "search_error", err)
 		return nil, err
 	}
 
-	if len(result.Entries) > 0 {
-		entry := result.Entries[0]
-		row := groupRow{
-			Dn:             entry.DN,
-			BaseDn:         *ldapConfig.BaseDN,
-			Cn:             entry.GetAttributeValue("cn"),
-			Description:    entry.GetAttributeValue("description"),
-			ObjectClass:    entry.GetAttributeValues("objectClass"),
-			Ou:             getOrganizationUnit(entry.DN),
-			Title:          entry.GetAttributeValue("title"),
-			ObjectSid:      getObjectSid(entry),
-			SamAccountName: entry.GetAttributeValue("sAMAccountName"),
-			MemberOf:       entry.GetAttributeValues("memberOf"),
-			Attributes:     transformAttributes(ctx, entry.Attributes),
-		}
-
-		// Populate Time fields
-		if !time.Time.IsZero(*convertToTimestamp(ctx, entry.GetAttributeValue("whenCreated"))) {
-			row.WhenCreated = convertToTimestamp(ctx, entry.GetAttributeValue("whenCreated"))
-		}
-		if !time.Time.IsZero(*convertToTimestamp(ctx, entry.GetAttributeValue("whenChanged"))) {
-			row.WhenChanged = convertToTimestamp(ctx, entry.GetAttributeValue("whenChanged"))
-		}
-
-		return row, nil
-	}
-
-	return nil, nil
+	return row, nil
 }
 
 func listGroups(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
 	logger := plugin.Logger(ctx)
 	logger.Trace("ldap_group.listGroups")
 
-	var baseDN, groupObjectFilter string
-	var attributes []string
-	var pageSize uint32 = PageSize
-
 	ldapConfig := GetConfig(d.Connection)
-	if ldapConfig.BaseDN != nil {
-		baseDN = *ldapConfig.BaseDN
-	}
-	if ldapConfig.Attributes != nil {
-		attributes = ldapConfig.Attributes
-	}
+	baseDNs := ldapConfig.baseDNs(d.Table.Name)
 
-	if ldapConfig.GroupObjectFilter != nil {
-		groupObjectFilter = *ldapConfig.GroupObjectFilter
+	profile, err := groupTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_group.listGroups", "profile_error", err)
+		return nil, err
 	}
 
-	keyQuals := d.EqualsQuals
+	filter := generateFilterString(d, groupTable.ObjectFilter(ldapConfig, profile), profile)
 
-	// default value for the group object filter if nothing is passed
-	if groupObjectFilter == "" {
-		groupObjectFilter = "(objectClass=group)"
-	}
+	// If no attributes are passed in, search request will get those needed by the columns of the query
+	attributes := groupTable.requestAttributes(d, profile)
 
-	filter := generateFilterString(d, groupObjectFilter)
-
-	logger.Debug("ldap_group.listGroups", "baseDN", baseDN)
+	logger.Debug("ldap_group.listGroups", "baseDNs", baseDNs)
 	logger.Debug("ldap_group.listGroups", "filter", filter)
 	logger.Debug("ldap_group.listGroups", "attributes", attributes)
 
-	if d.QueryContext.Limit != nil {
-		if uint32(*d.QueryContext.Limit) < pageSize {
-			pageSize = uint32(*d.QueryContext.Limit)
-		}
-	}
-
-	var searchReq *ldap.SearchRequest
-	paging := ldap.NewControlPaging(pageSize)
-
-	for {
-		// If no attributes are passed in, search request will get all of them
-		if attributes != nil {
-			searchReq = ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, 0, 0, 0, false, filter, attributes, []ldap.Control{paging})
-		} else {
-			searchReq = ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, 0, 0, 0, false, filter, []string{}, []ldap.Control{paging})
-		}
-
-		result, err := search(ctx, d, searchReq)
-		if err != nil {
-			plugin.Logger(ctx).Error("ldap_group.listGroups", "search_error", err)
-			return nil, err
-		}
-
-		for _, entry := range result.Entries {
-			row := groupRow{
-				Dn:             entry.DN,
-				BaseDn:         baseDN,
-				Cn:             entry.GetAttributeValue("cn"),
-				Description:    entry.GetAttributeValue("description"),
-				ObjectClass:    entry.GetAttributeValues("objectClass"),
-				Ou:             getOrganizationUnit(entry.DN),
-				Title:          entry.GetAttributeValue("title"),
-				ObjectSid:      getObjectSid(entry),
-				SamAccountName: entry.GetAttributeValue("sAMAccountName"),
-				MemberOf:       entry.GetAttributeValues("memberOf"),
-				Attributes:     transformAttributes(ctx, entry.Attributes),
-			}
-
-			if keyQuals["filter"] != nil {
-				row.Filter = keyQuals["filter"].GetStringValue()
-			}
-
-			// Populate time fields
-			if !time.Time.IsZero(*convertToTimestamp(ctx, entry.GetAttributeValue("whenCreated"))) {
-				row.WhenCreated = convertToTimestamp(ctx, entry.GetAttributeValue("whenCreated"))
-			}
-			if !time.Time.IsZero(*convertToTimestamp(ctx, entry.GetAttributeValue("whenChanged"))) {
-				row.WhenChanged = convertToTimestamp(ctx, entry.GetAttributeValue("whenChanged"))
-			}
-
-			d.StreamListItem(ctx, row)
-
-			// Check if context has been cancelled or if the limit has been hit (if specified)
-			if d.RowsRemaining(ctx) == 0 {
-				return nil, nil
-			}
-		}
-
-		// If the result control does not have paging or if the paging control does not
-		// have a next page cookie exit from the loop
-		resultCtrl := ldap.FindControl(result.Controls, paging.GetControlType())
-		if resultCtrl == nil {
-			break
-		}
-		if pagingCtrl, ok := resultCtrl.(*ldap.ControlPaging); ok {
-			if len(pagingCtrl.Cookie) == 0 {
-				break
-			}
-			paging.SetCookie(pagingCtrl.Cookie)
-		}
+	err = searchBaseDNs(ctx, d, baseDNs, filter, attributes, profile, func(entry *ldap.Entry, baseDN string) {
+		d.StreamListItem(ctx, groupTable.buildRow(ctx, d, entry, baseDN, profile))
+	})
+	if err != nil {
+		logger.Error("ldap_group.listGroups", "search_error", err)
+		return nil, err
 	}
 
 	return nil, nil
@@ -0,0 +1,256 @@
+package ldap
+
+import (
+	"context"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Filter matching the password settings objects
+const PasswordSettingsObjectFilter = "(objectClass=msDS-PasswordSettings)"
+
+var passwordSettingsObjectTable = &mappedTable{
+	Name: "ldap_password_settings_object",
+	ObjectFilter: func(ldapConfig, *directoryProfile) string {
+		return PasswordSettingsObjectFilter
+	},
+	Columns: []*mappedColumn{
+		// Top Columns
+		{
+			Name:        "dn",
+			Description: "Distinguished name of the password settings object.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "cn",
+			Description: "Common name of the password settings object.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "precedence",
+			Description: "The precedence of the password settings object. When several objects apply to a user, the one with the lowest precedence wins.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-PasswordSettingsPrecedence",
+			Value:       intValue,
+		},
+		{
+			Name:        "min_password_length",
+			Description: "The minimum number of characters that a password must contain.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-MinimumPasswordLength",
+			Value:       intValue,
+		},
+		{
+			Name:        "min_password_age_seconds",
+			Description: "The minimum amount of time, in seconds, that a password must be used before it can be changed.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-MinimumPasswordAge",
+			Value:       intervalValue,
+		},
+		{
+			Name:        "max_password_age_seconds",
+			Description: "The maximum amount of time, in seconds, that a password can be used before it must be changed. Null if passwords never expire.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-MaximumPasswordAge",
+			Value:       intervalValue,
+		},
+		{
+			Name:        "password_history_length",
+			Description: "The number of old passwords that are remembered and cannot be reused.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-PasswordHistoryLength",
+			Value:       intValue,
+		},
+		{
+			Name:        "complexity_enabled",
+			Description: "Whether passwords must meet complexity requirements.",
+			Type:        proto.ColumnType_BOOL,
+			Attribute:   "msDS-PasswordComplexityEnabled",
+			Value:       boolValue,
+		},
+		{
+			Name:        "reversible_encryption_enabled",
+			Description: "Whether passwords are stored using reversible encryption.",
+			Type:        proto.ColumnType_BOOL,
+			Attribute:   "msDS-PasswordReversibleEncryptionEnabled",
+			Value:       boolValue,
+		},
+		{
+			Name:        "lockout_threshold",
+			Description: "The number of failed logon attempts after which an account is locked out. 0 means accounts are never locked out.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-LockoutThreshold",
+			Value:       intValue,
+		},
+		{
+			Name:        "lockout_duration_seconds",
+			Description: "The amount of time, in seconds, that a locked out account remains locked. Null if accounts remain locked until an administrator unlocks them.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-LockoutDuration",
+			Value:       intervalValue,
+		},
+		{
+			Name:        "lockout_observation_window_seconds",
+			Description: "The amount of time, in seconds, after which the failed logon attempt counter is reset.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "msDS-LockoutObservationWindow",
+			Value:       intervalValue,
+		},
+		{
+			Name:        "when_created",
+			Description: "Date when the password settings object was created.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Value:       timestampValue,
+			Operators:   timestampOperators,
+		},
+		{
+			Name:        "when_changed",
+			Description: "Date when the password settings object was last changed.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Value:       timestampValue,
+			Operators:   timestampOperators,
+		},
+		{
+			Name:        "usn_created",
+			Description: "The update sequence number (USN) assigned by the domain controller when the password settings object was created. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "usn_changed",
+			Description: "The update sequence number (USN) assigned by the domain controller when the password settings object was last changed. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+
+		// Other Columns
+		{
+			Name:        "description",
+			Description: "Description of the password settings object.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "base_dn",
+			Description: "The Base DN on which the search was performed.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "scope",
+			Description: "The scope of the search, one of base, one or sub. Defaults to sub, i.e. the whole subtree of the base DN.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromQual("scope").Transform(defaultSearchScope),
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "filter",
+			Description: "Optional search filter.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+
+		// JSON Columns
+		{
+			Name:        "applies_to",
+			Description: "Distinguished names of the users and global security groups the password settings object applies to.",
+			Type:        proto.ColumnType_JSON,
+			Attribute:   "msDS-PSOAppliesTo",
+			Value:       valuesValue,
+		},
+		{
+			Name:        "object_class",
+			Description: "Object classes of the password settings object.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "attributes",
+			Description: "All attributes that have been returned from LDAP.",
+			Type:        proto.ColumnType_JSON,
+			Value:       attributesValue,
+		},
+
+		// Steampipe Columns
+		{
+			Name:        "title",
+			Description: "Title of the password settings object.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  columnAttributes("cn"),
+		},
+	},
+}
+
+func tableLDAPPasswordSettingsObject(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_password_settings_object",
+		Description: "A password settings object (PSO) defines a fine-grained password and account lockout policy for a set of users and groups.",
+		Get: &plugin.GetConfig{
+			KeyColumns: passwordSettingsObjectTable.getKeyColumns(),
+			Hydrate:    getPasswordSettingsObject,
+		},
+		List: &plugin.ListConfig{
+			Hydrate:    listPasswordSettingsObjects,
+			KeyColumns: passwordSettingsObjectTable.keyColumns(),
+		},
+		Columns: passwordSettingsObjectTable.columns(),
+	}
+}
+
+func getPasswordSettingsObject(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_password_settings_object.getPasswordSettingsObject")
+
+	profile, err := passwordSettingsObjectTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_password_settings_object.getPasswordSettingsObject", "profile_error", err)
+		return nil, err
+	}
+
+	row, err := passwordSettingsObjectTable.getRow(ctx, d, profile)
+	if err != nil {
+		logger.Error("ldap_password_settings_object.getPasswordSettingsObject", "search_error", err)
+		return nil, err
+	}
+
+	return row, nil
+}
+
+func listPasswordSettingsObjects(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_password_settings_object.listPasswordSettingsObjects")
+
+	ldapConfig := GetConfig(d.Connection)
+	baseDNs := ldapConfig.baseDNs(d.Table.Name)
+
+	profile, err := passwordSettingsObjectTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_password_settings_object.listPasswordSettingsObjects", "profile_error", err)
+		return nil, err
+	}
+
+	filter := generateFilterString(d, passwordSettingsObjectTable.ObjectFilter(ldapConfig, profile), profile)
+
+	// If no attributes are passed in, search request will get those needed by the columns of the query
+	attributes := passwordSettingsObjectTable.requestAttributes(d, profile)
+
+	logger.Debug("ldap_password_settings_object.listPasswordSettingsObjects", "baseDNs", baseDNs)
+	logger.Debug("ldap_password_settings_object.listPasswordSettingsObjects", "filter", filter)
+	logger.Debug("ldap_password_settings_object.listPasswordSettingsObjects", "attributes", attributes)
+
+	err = searchBaseDNs(ctx, d, baseDNs, filter, attributes, profile, func(entry *ldap.Entry, baseDN string) {
+		d.StreamListItem(ctx, passwordSettingsObjectTable.buildRow(ctx, d, entry, baseDN, profile))
+	})
+	if err != nil {
+		logger.Error("ldap_password_settings_object.listPasswordSettingsObjects", "search_error", err)
+		return nil, err
+	}
+
+	return nil, nil
+}
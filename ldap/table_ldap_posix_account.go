@@ -0,0 +1,260 @@
+package ldap
+
+import (
+	"context"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Default filter matching the POSIX accounts, used when posix_account_object_filter is not set
+const DefaultPosixAccountObjectFilter = "(objectClass=posixAccount)"
+
+var posixAccountTable = &mappedTable{
+	Name: "ldap_posix_account",
+	ObjectFilter: func(ldapConfig ldapConfig, _ *directoryProfile) string {
+		return configuredFilter(ldapConfig.PosixAccountObjectFilter, DefaultPosixAccountObjectFilter)
+	},
+	OperationalAttributes: timestampOperationalAttributes,
+	Columns: []*mappedColumn{
+		// Top Columns
+		{
+			Name:        "dn",
+			Description: "Distinguished name of the account.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "uid",
+			Description: "Login name of the account.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "cn",
+			Description: "Full name of the account.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "uid_number",
+			Description: "Numeric user ID of the account.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "gid_number",
+			Description: "Numeric ID of the primary group of the account.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "home_directory",
+			Description: "Absolute path to the home directory of the account.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "login_shell",
+			Description: "Path to the login shell of the account.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "gecos",
+			Description: "The GECOS field of the account, usually the full name and contact details of the user.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "ou",
+			Description: "Organizational unit to which the account belongs to.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  noAttributes,
+			Value:       organizationalUnitValue,
+		},
+		{
+			Name:        "shadow_last_change",
+			Description: "Date when the password of the account was last changed.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Value:       daysValue,
+		},
+		{
+			Name:        "shadow_min",
+			Description: "Minimum number of days that must elapse between password changes.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "shadow_max",
+			Description: "Maximum number of days that a password is valid.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "shadow_warning",
+			Description: "Number of days before the password expires that the user is warned.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "shadow_inactive",
+			Description: "Number of days after the password has expired that the account is disabled.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "shadow_expire",
+			Description: "Date when the account expires.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Value:       daysValue,
+		},
+		{
+			Name:        "when_created",
+			Description: "Date when the account was created.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Attribute:   "createTimestamp",
+			Value:       timestampValue,
+		},
+		{
+			Name:        "when_changed",
+			Description: "Date when the account was last changed.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Attribute:   "modifyTimestamp",
+			Value:       timestampValue,
+		},
+		{
+			Name:        "usn_created",
+			Description: "The update sequence number (USN) assigned by the domain controller when the account was created. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "usn_changed",
+			Description: "The update sequence number (USN) assigned by the domain controller when the account was last changed. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+
+		// Other Columns
+		{
+			Name:        "description",
+			Description: "Description of the account.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "base_dn",
+			Description: "The Base DN on which the search was performed.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "scope",
+			Description: "The scope of the search, one of base, one or sub. Defaults to sub, i.e. the whole subtree of the base DN.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromQual("scope").Transform(defaultSearchScope),
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "filter",
+			Description: "Optional search filter.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+
+		// JSON Columns
+		{
+			Name:        "object_class",
+			Description: "Object classes of the account.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "attributes",
+			Description: "All attributes that have been returned from LDAP.",
+			Type:        proto.ColumnType_JSON,
+			Value:       attributesValue,
+		},
+
+		// Steampipe Columns
+		{
+			Name:        "title",
+			Description: "Title of the account.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  columnAttributes("uid"),
+		},
+	},
+}
+
+func tableLDAPPosixAccount(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_posix_account",
+		Description: "A POSIX account is a Unix user account, as defined by the posixAccount and shadowAccount object classes.",
+		Get: &plugin.GetConfig{
+			KeyColumns: posixAccountTable.getKeyColumns(),
+			Hydrate:    getPosixAccount,
+		},
+		List: &plugin.ListConfig{
+			Hydrate:    listPosixAccounts,
+			KeyColumns: posixAccountTable.keyColumns(),
+		},
+		Columns: posixAccountTable.columns(),
+	}
+}
+
+func getPosixAccount(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_posix_account.getPosixAccount")
+
+	profile, err := posixAccountTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_posix_account.getPosixAccount", "profile_error", err)
+		return nil, err
+	}
+
+	row, err := posixAccountTable.getRow(ctx, d, profile)
+	if err != nil {
+		logger.Error("ldap_posix_account.getPosixAccount", "search_error", err)
+		return nil, err
+	}
+
+	return row, nil
+}
+
+func listPosixAccounts(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_posix_account.listPosixAccounts")
+
+	ldapConfig := GetConfig(d.Connection)
+	baseDNs := ldapConfig.baseDNs(d.Table.Name)
+
+	profile, err := posixAccountTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_posix_account.listPosixAccounts", "profile_error", err)
+		return nil, err
+	}
+
+	filter := generateFilterString(d, posixAccountTable.ObjectFilter(ldapConfig, profile), profile)
+
+	// If no attributes are passed in, search request will get those needed by the columns of the query
+	attributes := posixAccountTable.requestAttributes(d, profile)
+
+	logger.Debug("ldap_posix_account.listPosixAccounts", "baseDNs", baseDNs)
+	logger.Debug("ldap_posix_account.listPosixAccounts", "filter", filter)
+	logger.Debug("ldap_posix_account.listPosixAccounts", "attributes", attributes)
+
+	err = searchBaseDNs(ctx, d, baseDNs, filter, attributes, profile, func(entry *ldap.Entry, baseDN string) {
+		d.StreamListItem(ctx, posixAccountTable.buildRow(ctx, d, entry, baseDN, profile))
+	})
+	if err != nil {
+		logger.Error("ldap_posix_account.listPosixAccounts", "search_error", err)
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,200 @@
+package ldap
+
+import (
+	"context"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Default filter matching the POSIX groups, used when posix_group_object_filter is not set
+const DefaultPosixGroupObjectFilter = "(objectClass=posixGroup)"
+
+var posixGroupTable = &mappedTable{
+	Name: "ldap_posix_group",
+	ObjectFilter: func(ldapConfig ldapConfig, _ *directoryProfile) string {
+		return configuredFilter(ldapConfig.PosixGroupObjectFilter, DefaultPosixGroupObjectFilter)
+	},
+	OperationalAttributes: timestampOperationalAttributes,
+	Columns: []*mappedColumn{
+		// Top Columns
+		{
+			Name:        "dn",
+			Description: "Distinguished name of the group.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "cn",
+			Description: "Name of the group.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "gid_number",
+			Description: "Numeric group ID of the group.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "ou",
+			Description: "Organizational unit to which the group belongs to.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  noAttributes,
+			Value:       organizationalUnitValue,
+		},
+		{
+			Name:        "when_created",
+			Description: "Date when the group was created.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Attribute:   "createTimestamp",
+			Value:       timestampValue,
+		},
+		{
+			Name:        "when_changed",
+			Description: "Date when the group was last changed.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Attribute:   "modifyTimestamp",
+			Value:       timestampValue,
+		},
+		{
+			Name:        "usn_created",
+			Description: "The update sequence number (USN) assigned by the domain controller when the group was created. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "usn_changed",
+			Description: "The update sequence number (USN) assigned by the domain controller when the group was last changed. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+
+		// Other Columns
+		{
+			Name:        "description",
+			Description: "Description of the group.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "base_dn",
+			Description: "The Base DN on which the search was performed.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "scope",
+			Description: "The scope of the search, one of base, one or sub. Defaults to sub, i.e. the whole subtree of the base DN.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromQual("scope").Transform(defaultSearchScope),
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "filter",
+			Description: "Optional search filter.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+
+		// JSON Columns
+		{
+			Name:        "member_uid",
+			Description: "Login names of the members of the group.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "object_class",
+			Description: "Object classes of the group.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "attributes",
+			Description: "All attributes that have been returned from LDAP.",
+			Type:        proto.ColumnType_JSON,
+			Value:       attributesValue,
+		},
+
+		// Steampipe Columns
+		{
+			Name:        "title",
+			Description: "Title of the group.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  columnAttributes("cn"),
+		},
+	},
+}
+
+func tableLDAPPosixGroup(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_posix_group",
+		Description: "A POSIX group is a Unix group, as defined by the posixGroup object class.",
+		Get: &plugin.GetConfig{
+			KeyColumns: posixGroupTable.getKeyColumns(),
+			Hydrate:    getPosixGroup,
+		},
+		List: &plugin.ListConfig{
+			Hydrate:    listPosixGroups,
+			KeyColumns: posixGroupTable.keyColumns(),
+		},
+		Columns: posixGroupTable.columns(),
+	}
+}
+
+func getPosixGroup(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_posix_group.getPosixGroup")
+
+	profile, err := posixGroupTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_posix_group.getPosixGroup", "profile_error", err)
+		return nil, err
+	}
+
+	row, err := posixGroupTable.getRow(ctx, d, profile)
+	if err != nil {
+		logger.Error("ldap_posix_group.getPosixGroup", "search_error", err)
+		return nil, err
+	}
+
+	return row, nil
+}
+
+func listPosixGroups(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_posix_group.listPosixGroups")
+
+	ldapConfig := GetConfig(d.Connection)
+	baseDNs := ldapConfig.baseDNs(d.Table.Name)
+
+	profile, err := posixGroupTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_posix_group.listPosixGroups", "profile_error", err)
+		return nil, err
+	}
+
+	filter := generateFilterString(d, posixGroupTable.ObjectFilter(ldapConfig, profile), profile)
+
+	// If no attributes are passed in, search request will get those needed by the columns of the query
+	attributes := posixGroupTable.requestAttributes(d, profile)
+
+	logger.Debug("ldap_posix_group.listPosixGroups", "baseDNs", baseDNs)
+	logger.Debug("ldap_posix_group.listPosixGroups", "filter", filter)
+	logger.Debug("ldap_posix_group.listPosixGroups", "attributes", attributes)
+
+	err = searchBaseDNs(ctx, d, baseDNs, filter, attributes, profile, func(entry *ldap.Entry, baseDN string) {
+		d.StreamListItem(ctx, posixGroupTable.buildRow(ctx, d, entry, baseDN, profile))
+	})
+	if err != nil {
+		logger.Error("ldap_posix_group.listPosixGroups", "search_error", err)
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,276 @@
+package ldap
+
+import (
+	"context"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Filter matching the ppolicy password policies
+const PpolicyObjectFilter = "(objectClass=pwdPolicy)"
+
+var ppolicyTable = &mappedTable{
+	Name: "ldap_ppolicy",
+	ObjectFilter: func(ldapConfig, *directoryProfile) string {
+		return PpolicyObjectFilter
+	},
+	OperationalAttributes: timestampOperationalAttributes,
+	Columns: []*mappedColumn{
+		// Top Columns
+		{
+			Name:        "dn",
+			Description: "Distinguished name of the password policy.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "cn",
+			Description: "Common name of the password policy.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "pwd_attribute",
+			Description: "The name of the attribute the password policy applies to, usually userPassword.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "pwd_min_length",
+			Description: "The minimum number of characters that a password must contain.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_min_age",
+			Description: "The minimum number of seconds that must elapse between password changes.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_max_age",
+			Description: "The number of seconds after which a password expires. 0 means passwords never expire.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_in_history",
+			Description: "The number of old passwords that are remembered and cannot be reused.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_check_quality",
+			Description: "Whether password quality is checked. 0 disables checking, 1 checks when possible and 2 rejects passwords that cannot be checked.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_lockout",
+			Description: "Whether accounts are locked after pwd_max_failure consecutive failed authentication attempts.",
+			Type:        proto.ColumnType_BOOL,
+			Value:       boolValue,
+		},
+		{
+			Name:        "pwd_max_failure",
+			Description: "The number of consecutive failed authentication attempts after which the account is locked.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_lockout_duration",
+			Description: "The number of seconds that a locked account remains locked. 0 means the account remains locked until an administrator unlocks it.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_failure_count_interval",
+			Description: "The number of seconds after which failed authentication attempts are purged from the failure counter.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_expire_warning",
+			Description: "The number of seconds before expiry that a password expiry warning is returned to the user.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_grace_authn_limit",
+			Description: "The number of times an expired password can be used to authenticate.",
+			Type:        proto.ColumnType_INT,
+			Attribute:   "pwdGraceAuthNLimit",
+			Value:       intValue,
+		},
+		{
+			Name:        "pwd_must_change",
+			Description: "Whether users must change their password after it has been reset by an administrator.",
+			Type:        proto.ColumnType_BOOL,
+			Value:       boolValue,
+		},
+		{
+			Name:        "pwd_allow_user_change",
+			Description: "Whether users are allowed to change their own password.",
+			Type:        proto.ColumnType_BOOL,
+			Value:       boolValue,
+		},
+		{
+			Name:        "pwd_safe_modify",
+			Description: "Whether the existing password must be sent along with the new password when changing it.",
+			Type:        proto.ColumnType_BOOL,
+			Value:       boolValue,
+		},
+		{
+			Name:        "pwd_max_idle",
+			Description: "The number of seconds of inactivity after which the account is locked.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+		},
+		{
+			Name:        "when_created",
+			Description: "Date when the password policy was created.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Attribute:   "createTimestamp",
+			Value:       timestampValue,
+		},
+		{
+			Name:        "when_changed",
+			Description: "Date when the password policy was last changed.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Attribute:   "modifyTimestamp",
+			Value:       timestampValue,
+		},
+		{
+			Name:        "usn_created",
+			Description: "The update sequence number (USN) assigned by the domain controller when the password policy was created. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "usn_changed",
+			Description: "The update sequence number (USN) assigned by the domain controller when the password policy was last changed. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+
+		// Other Columns
+		{
+			Name:        "description",
+			Description: "Description of the password policy.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "base_dn",
+			Description: "The Base DN on which the search was performed.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "scope",
+			Description: "The scope of the search, one of base, one or sub. Defaults to sub, i.e. the whole subtree of the base DN.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromQual("scope").Transform(defaultSearchScope),
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "filter",
+			Description: "Optional search filter.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+
+		// JSON Columns
+		{
+			Name:        "object_class",
+			Description: "Object classes of the password policy.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "attributes",
+			Description: "All attributes that have been returned from LDAP.",
+			Type:        proto.ColumnType_JSON,
+			Value:       attributesValue,
+		},
+
+		// Steampipe Columns
+		{
+			Name:        "title",
+			Description: "Title of the password policy.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  columnAttributes("cn"),
+		},
+	},
+}
+
+func tableLDAPPpolicy(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_ppolicy",
+		Description: "A password policy entry used by the OpenLDAP ppolicy overlay.",
+		Get: &plugin.GetConfig{
+			KeyColumns: ppolicyTable.getKeyColumns(),
+			Hydrate:    getPpolicy,
+		},
+		List: &plugin.ListConfig{
+			Hydrate:    listPpolicies,
+			KeyColumns: ppolicyTable.keyColumns(),
+		},
+		Columns: ppolicyTable.columns(),
+	}
+}
+
+func getPpolicy(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_ppolicy.getPpolicy")
+
+	profile, err := ppolicyTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_ppolicy.getPpolicy", "profile_error", err)
+		return nil, err
+	}
+
+	row, err := ppolicyTable.getRow(ctx, d, profile)
+	if err != nil {
+		logger.Error("ldap_ppolicy.getPpolicy", "search_error", err)
+		return nil, err
+	}
+
+	return row, nil
+}
+
+func listPpolicies(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_ppolicy.listPpolicies")
+
+	ldapConfig := GetConfig(d.Connection)
+	baseDNs := ldapConfig.baseDNs(d.Table.Name)
+
+	profile, err := ppolicyTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_ppolicy.listPpolicies", "profile_error", err)
+		return nil, err
+	}
+
+	filter := generateFilterString(d, ppolicyTable.ObjectFilter(ldapConfig, profile), profile)
+
+	// If no attributes are passed in, search request will get those needed by the columns of the query
+	attributes := ppolicyTable.requestAttributes(d, profile)
+
+	logger.Debug("ldap_ppolicy.listPpolicies", "baseDNs", baseDNs)
+	logger.Debug("ldap_ppolicy.listPpolicies", "filter", filter)
+	logger.Debug("ldap_ppolicy.listPpolicies", "attributes", attributes)
+
+	err = searchBaseDNs(ctx, d, baseDNs, filter, attributes, profile, func(entry *ldap.Entry, baseDN string) {
+		d.StreamListItem(ctx, ppolicyTable.buildRow(ctx, d, entry, baseDN, profile))
+	})
+	if err != nil {
+		logger.Error("ldap_ppolicy.listPpolicies", "search_error", err)
+		return nil, err
+	}
+
+	return nil, nil
+}
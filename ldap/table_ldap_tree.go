@@ -0,0 +1,362 @@
+package ldap
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+// Attributes read from the nodes of the tree: the operational attributes telling whether an entry has children,
+// i.e. hasSubordinates (OpenLDAP, 389 DS) and msDS-Approx-Immed-Subordinates (Active Directory), spare
+// a one-level search under every leaf
+var treeAttributes = []string{"objectClass", "hasSubordinates", "msDS-Approx-Immed-Subordinates", "uSNCreated", "uSNChanged"}
+
+type treeRow struct {
+	// Distinguished name
+	Dn string
+	// Base DN of the walk the node was found by
+	BaseDn string
+	// Value of the RDN of the node
+	Name string
+	// Object class
+	ObjectClass []string
+	// Number of children
+	ChildCount int
+	// Number of children by object class
+	ChildCounts map[string]int
+	// Update sequence numbers
+	UsnCreated *int64
+	UsnChanged *int64
+}
+
+func tableLDAPTree(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_tree",
+		Description: "The entries of the directory tree, along with the number of children of each by object class.",
+		Get: &plugin.GetConfig{
+			KeyColumns: plugin.SingleColumn("dn"),
+			Hydrate:    getTreeNode,
+		},
+		List: &plugin.ListConfig{
+			Hydrate: listTreeNodes,
+			KeyColumns: []*plugin.KeyColumn{
+				{Name: "base_dn", Require: plugin.Optional},
+				{Name: "parent_dn", Require: plugin.Optional},
+				{Name: "depth", Operators: []string{"=", "<", "<="}, Require: plugin.Optional},
+			},
+		},
+		Columns: commonColumns([]*plugin.Column{
+			// Top Columns
+			{
+				Name:        "dn",
+				Description: "Distinguished name of the entry.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "name",
+				Description: "Value of the relative distinguished name of the entry, e.g. Users for CN=Users,DC=example,DC=com.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "child_count",
+				Description: "Number of entries immediately beneath the entry.",
+				Type:        proto.ColumnType_INT,
+			},
+			{
+				Name:        "usn_created",
+				Description: "The update sequence number (USN) assigned by the domain controller when the entry was created. Only maintained by Active Directory.",
+				Type:        proto.ColumnType_INT,
+			},
+			{
+				Name:        "usn_changed",
+				Description: "The update sequence number (USN) assigned by the domain controller when the entry was last changed. Only maintained by Active Directory.",
+				Type:        proto.ColumnType_INT,
+			},
+
+			// Other Columns
+			{
+				Name:        "base_dn",
+				Description: "The Base DN from which the tree was walked.",
+				Type:        proto.ColumnType_STRING,
+			},
+
+			// JSON Columns
+			{
+				Name:        "object_class",
+				Description: "Object classes of the entry.",
+				Type:        proto.ColumnType_JSON,
+			},
+			{
+				Name:        "child_counts",
+				Description: "Number of entries immediately beneath the entry by object class, e.g. {\"organizationalUnit\": 3, \"user\": 120}. A child is counted under each of its object classes but top.",
+				Type:        proto.ColumnType_JSON,
+			},
+
+			// Steampipe Columns
+			{
+				Name:        "title",
+				Description: "Title of the entry.",
+				Type:        proto.ColumnType_STRING,
+				Transform:   transform.FromField("Name"),
+			},
+		}),
+	}
+}
+
+func getTreeNode(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_tree.getTreeNode")
+
+	dn := d.EqualsQuals["dn"].GetStringValue()
+
+	ldapConfig := GetConfig(d.Connection)
+
+	searchReq := ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, 0, 1, 0, false, "(objectClass=*)", treeAttributes, []ldap.Control{})
+
+	result, err := search(ctx, d, searchReq)
+	if err != nil {
+		logger.Error("ldap_tree.getTreeNode", "search_error", err)
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, nil
+	}
+
+	entry := result.Entries[0]
+	children, err := treeChildren(ctx, d, entry)
+	if err != nil {
+		logger.Error("ldap_tree.getTreeNode", "search_error", err)
+		return nil, err
+	}
+
+	return buildTreeRow(ctx, entry, ldapConfig.baseDNOf(d.Table.Name, entry.DN), children), nil
+}
+
+// listTreeNodes lists the children of the parent_dn quals, or else walks the tree beneath each base DN depth first,
+// listing every entry before its children. Each entry is read by a one-level search under its parent, which
+// counts the children of the parent as well
+func listTreeNodes(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_tree.listTreeNodes")
+
+	ldapConfig := GetConfig(d.Connection)
+	minDepth, maxDepth := queryDepthRange(d)
+
+	if qual := d.EqualsQuals["parent_dn"]; qual != nil {
+		parentDNs := []string{qual.GetStringValue()}
+		if qual.GetListValue() != nil {
+			parentDNs = []string{}
+			for _, value := range qual.GetListValue().Values {
+				parentDNs = append(parentDNs, value.GetStringValue())
+			}
+		}
+
+		logger.Debug("ldap_tree.listTreeNodes", "parentDNs", parentDNs)
+
+		// Entries are filtered by depth and their children are searched for, so the page size is not lowered to the limit
+		pageSize, err := configuredPageSize(d)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, parentDN := range parentDNs {
+			var entries []*ldap.Entry
+			err := searchPaged(ctx, d, parentDN, ldap.ScopeSingleLevel, "(objectClass=*)", treeAttributes, nil, pageSize, func(entry *ldap.Entry) {
+				entries = append(entries, entry)
+			})
+			if err != nil {
+				logger.Error("ldap_tree.listTreeNodes", "search_error", err)
+				return nil, err
+			}
+
+			for _, entry := range entries {
+				if depth := treeDepth(entry.DN); depth < minDepth || depth > maxDepth {
+					continue
+				}
+				children, err := treeChildren(ctx, d, entry)
+				if err != nil {
+					logger.Error("ldap_tree.listTreeNodes", "search_error", err)
+					return nil, err
+				}
+				d.StreamListItem(ctx, buildTreeRow(ctx, entry, ldapConfig.baseDNOf(d.Table.Name, entry.DN), children))
+
+				// Context can be cancelled due to manual cancellation or the limit has been hit
+				if d.RowsRemaining(ctx) == 0 {
+					return nil, nil
+				}
+			}
+		}
+
+		return nil, nil
+	}
+
+	baseDNs, _, err := querySearchBases(ctx, d, ldapConfig.baseDNs(d.Table.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Debug("ldap_tree.listTreeNodes", "baseDNs", baseDNs, "minDepth", minDepth, "maxDepth", maxDepth)
+
+	for _, baseDN := range treeRoots(baseDNs) {
+		searchReq := ldap.NewSearchRequest(baseDN, ldap.ScopeBaseObject, 0, 1, 0, false, "(objectClass=*)", treeAttributes, []ldap.Control{})
+
+		result, err := search(ctx, d, searchReq)
+		if err != nil {
+			logger.Error("ldap_tree.listTreeNodes", "search_error", err)
+			return nil, err
+		}
+
+		for _, entry := range result.Entries {
+			if err := walkTree(ctx, d, entry, baseDN, minDepth, maxDepth); err != nil {
+				logger.Error("ldap_tree.listTreeNodes", "search_error", err)
+				return nil, err
+			}
+		}
+
+		if d.RowsRemaining(ctx) == 0 {
+			return nil, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// walkTree streams the row of an entry unless it is shallower than minDepth, then walks the tree beneath it down to maxDepth
+func walkTree(ctx context.Context, d *plugin.QueryData, entry *ldap.Entry, baseDN string, minDepth int, maxDepth int) error {
+	depth := treeDepth(entry.DN)
+	if depth > maxDepth {
+		return nil
+	}
+
+	children, err := treeChildren(ctx, d, entry)
+	if err != nil {
+		return err
+	}
+
+	if depth >= minDepth {
+		d.StreamListItem(ctx, buildTreeRow(ctx, entry, baseDN, children))
+	}
+
+	for _, child := range children {
+		// Context can be cancelled due to manual cancellation or the limit has been hit
+		if d.RowsRemaining(ctx) == 0 {
+			return nil
+		}
+		if err := walkTree(ctx, d, child, baseDN, minDepth, maxDepth); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// treeChildren returns the entries immediately beneath an entry, without searching under those the server
+// reports as having none
+func treeChildren(ctx context.Context, d *plugin.QueryData, entry *ldap.Entry) ([]*ldap.Entry, error) {
+	if strings.EqualFold(entry.GetEqualFoldAttributeValue("hasSubordinates"), "FALSE") || entry.GetEqualFoldAttributeValue("msDS-Approx-Immed-Subordinates") == "0" {
+		return nil, nil
+	}
+
+	// All the children are read to count them, so the page size is not lowered to the limit
+	pageSize, err := configuredPageSize(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var children []*ldap.Entry
+	err = searchPaged(ctx, d, entry.DN, ldap.ScopeSingleLevel, "(objectClass=*)", treeAttributes, nil, pageSize, func(child *ldap.Entry) {
+		children = append(children, child)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return children, nil
+}
+
+// treeRoots returns the base DNs which are not beneath another one, so that no entry is walked twice
+func treeRoots(baseDNs []string) []string {
+	var roots []string
+	for i, baseDN := range baseDNs {
+		nested := false
+		for j, other := range baseDNs {
+			if i == j {
+				continue
+			}
+			// Of two equal base DNs, keep the first one
+			if isDescendantDN(baseDN, other) && (normalizeDN(baseDN) != normalizeDN(other) || j < i) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			roots = append(roots, baseDN)
+		}
+	}
+	return roots
+}
+
+// queryDepthRange returns the least and greatest depths allowed by the depth quals of the query, so that the walk
+// only streams the entries in between and stops at the greatest one. An = qual is both bounds
+func queryDepthRange(d *plugin.QueryData) (int, int) {
+	minDepth, maxDepth := 0, math.MaxInt
+	if d.Quals["depth"] == nil {
+		return minDepth, maxDepth
+	}
+	for _, q := range d.Quals["depth"].Quals {
+		depth := int(q.Value.GetInt64Value())
+		if q.Operator == "=" && depth > minDepth {
+			minDepth = depth
+		}
+		if q.Operator == "<" {
+			depth--
+		}
+		if depth < maxDepth {
+			maxDepth = depth
+		}
+	}
+	return minDepth, maxDepth
+}
+
+// treeDepth returns the number of RDNs of a DN
+func treeDepth(dn string) int {
+	parsed, err := parseDN(dn)
+	if err != nil {
+		return 0
+	}
+	return len(parsed.RDNs)
+}
+
+func buildTreeRow(ctx context.Context, entry *ldap.Entry, baseDN string, children []*ldap.Entry) treeRow {
+	row := treeRow{
+		Dn:          entry.DN,
+		BaseDn:      baseDN,
+		ObjectClass: entry.GetEqualFoldAttributeValues("objectClass"),
+		ChildCount:  len(children),
+		ChildCounts: map[string]int{},
+		UsnCreated:  convertToInt(ctx, entry.GetEqualFoldAttributeValue("uSNCreated")),
+		UsnChanged:  convertToInt(ctx, entry.GetEqualFoldAttributeValue("uSNChanged")),
+	}
+
+	if parsed, err := parseDN(entry.DN); err == nil && len(parsed.RDNs) > 0 && len(parsed.RDNs[0].Attributes) > 0 {
+		row.Name = parsed.RDNs[0].Attributes[0].Value
+	}
+	if row.Name == "" {
+		row.Name = entry.DN
+	}
+
+	for _, child := range children {
+		for _, objectClass := range child.GetEqualFoldAttributeValues("objectClass") {
+			if strings.EqualFold(objectClass, "top") {
+				continue
+			}
+			row.ChildCounts[objectClass]++
+		}
+	}
+
+	return row
+}
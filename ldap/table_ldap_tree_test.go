@@ -0,0 +1,38 @@
+package ldap
+
+import (
+	"math"
+	"testing"
+
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+func TestQueryDepthRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		operators []string
+		depth     int64
+		wantMin   int
+		wantMax   int
+	}{
+		{name: "no quals", wantMin: 0, wantMax: math.MaxInt},
+		{name: "equal", operators: []string{"="}, depth: 3, wantMin: 3, wantMax: 3},
+		{name: "lower than", operators: []string{"<"}, depth: 3, wantMin: 0, wantMax: 2},
+		{name: "lower than or equal", operators: []string{"<="}, depth: 3, wantMin: 0, wantMax: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quals := plugin.KeyColumnQualMap{}
+			if len(test.operators) > 0 {
+				quals["depth"] = qualList("depth", &proto.QualValue{Value: &proto.QualValue_Int64Value{Int64Value: test.depth}}, test.operators...)
+			}
+
+			gotMin, gotMax := queryDepthRange(&plugin.QueryData{Quals: quals})
+			if gotMin != test.wantMin || gotMax != test.wantMax {
+				t.Errorf("queryDepthRange() = %d, %d, want %d, %d", gotMin, gotMax, test.wantMin, test.wantMax)
+			}
+		})
+	}
+}
@@ -3,7 +3,6 @@ package ldap
 import (
 	"context"
 	"strconv"
-	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
@@ -11,53 +10,274 @@ import (
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
 )
 
-type userRow struct {
-	// Distinguished name
-	Dn string
-	// Base domain name
-	BaseDn string
-	// Filter string
-	Filter string
-	// Common name / Full name
-	Cn string
-	// Description
-	Description string
-	// Display name / Full name
-	DisplayName string
-	// First name
-	GivenName string
-	// Middle initials
-	Initials string
-	// Email address
-	Mail string
-	// Creation date
-	WhenCreated *time.Time
-	// Last modified date
-	WhenChanged *time.Time
-	// Object class
-	ObjectClass []string
-	// Organizational unit the user belongs to
-	Ou string
-	// Last name
-	Surname string
-	// Department
-	Department string
-	// Object SID
-	ObjectSid string
-	// SAM account name
-	SamAccountName string
-	// User principal name
-	UserPrincipalName string
-	// Title
-	Title string
-	// Job title
-	JobTitle string
-	// Groups the user belongs to
-	MemberOf []string
-	// Whether the user account is disabled
-	Disabled *bool
-	// All attributes that are configured to be returned
-	Attributes map[string][]string
+// Constructed and operational attributes are not returned when all attributes are requested, so they must be requested explicitly
+// msDS-ResultantPSO is constructed by AD, the pwd* attributes are maintained by the OpenLDAP ppolicy overlay
+var userOperationalAttributes = []string{"msDS-ResultantPSO", "pwdChangedTime", "pwdAccountLockedTime", "pwdFailureTime", "pwdPolicySubentry"}
+
+var userTable = &mappedTable{
+	Name: "ldap_user",
+	ObjectFilter: func(ldapConfig ldapConfig, profile *directoryProfile) string {
+		return configuredFilter(ldapConfig.UserObjectFilter, profile.UserObjectFilter)
+	},
+	OperationalAttributes: userOperationalAttributes,
+	Columns: []*mappedColumn{
+		// Top Columns
+		{
+			Name:        "dn",
+			Description: "Distinguished name of the user.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "cn",
+			Description: "Full name of the user.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "display_name",
+			Description: "Display name of the user.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "object_sid",
+			Description: "The security identifier (SID) of the user.",
+			Type:        proto.ColumnType_STRING,
+			Value:       objectSidValue,
+			Operators:   equalsOperators,
+			Get:         true,
+		},
+		{
+			Name:        "object_guid",
+			Description: "The globally unique identifier (GUID) of the user.",
+			Type:        proto.ColumnType_STRING,
+			Value:       objectGUIDValue,
+			Operators:   equalsOperators,
+			Get:         true,
+		},
+		{
+			Name:        "given_name",
+			Description: "Given name of the user.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "surname",
+			Description: "Family name of the user.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "initials",
+			Description: "Initials of the user.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "mail",
+			Description: "E-mail address of the user.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "department",
+			Description: "Department to which the user belongs to.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "when_created",
+			Description: "Date when the user was created.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Sort:        plugin.SortAll,
+			Value:       timestampValue,
+			Operators:   timestampOperators,
+		},
+		{
+			Name:        "when_changed",
+			Description: "Date when the user was last changed.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Sort:        plugin.SortAll,
+			Value:       timestampValue,
+			Operators:   timestampOperators,
+		},
+		{
+			Name:        "usn_created",
+			Description: "The update sequence number (USN) assigned by the domain controller when the user was created. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Sort:        plugin.SortAll,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "usn_changed",
+			Description: "The update sequence number (USN) assigned by the domain controller when the user was last changed. Only maintained by Active Directory.",
+			Type:        proto.ColumnType_INT,
+			Sort:        plugin.SortAll,
+			Value:       intValue,
+			Operators:   usnOperators,
+		},
+		{
+			Name:        "sam_account_name",
+			Description: "Logon name (pre-Windows 2000) of the user.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+			Get:         true,
+		},
+		{
+			Name:        "user_principal_name",
+			Description: "Login name of the user, usually mapped to the user email name.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "job_title",
+			Description: "Job title of the user.",
+			Type:        proto.ColumnType_STRING,
+		},
+		{
+			Name:        "manager_dn",
+			Description: "Distinguished name of the manager of the user.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "ou",
+			Description: "Organizational unit to which the user belongs to.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  noAttributes,
+			Value:       organizationalUnitValue,
+		},
+		{
+			Name:        "disabled",
+			Description: "Whether the user account is disabled.",
+			Type:        proto.ColumnType_BOOL,
+			Attributes: func(profile *directoryProfile, _ string) []string {
+				return profile.disabledAttributes()
+			},
+			Value: func(ctx context.Context, entry *ldap.Entry, _ []string, profile *directoryProfile) interface{} {
+				return profile.isDisabled(ctx, entry)
+			},
+			Operators: boolOperators,
+		},
+		{
+			Name:        "resultant_pso",
+			Description: "Distinguished name of the password settings object that applies to the user. Empty if the domain password policy applies.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  fixedAttributes("msDS-ResultantPSO"),
+		},
+		{
+			Name:        "pwd_changed_time",
+			Description: "Date when the password of the user was last changed, as maintained by the ppolicy overlay.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Attributes:  fixedAttributes("pwdChangedTime"),
+			Value:       timestampValue,
+		},
+		{
+			Name:        "pwd_account_locked",
+			Description: "Whether the account of the user has been locked by the ppolicy overlay.",
+			Type:        proto.ColumnType_BOOL,
+			Attributes:  fixedAttributes("pwdAccountLockedTime"),
+			Value: func(_ context.Context, entry *ldap.Entry, attributes []string, _ *directoryProfile) interface{} {
+				return entry.GetEqualFoldAttributeValue(attributes[0]) != ""
+			},
+		},
+		{
+			Name:        "pwd_account_locked_time",
+			Description: "Date when the account of the user was locked by the ppolicy overlay. Null if the account is not locked or has been locked permanently by an administrator.",
+			Type:        proto.ColumnType_TIMESTAMP,
+			Attributes:  fixedAttributes("pwdAccountLockedTime"),
+			Value: func(ctx context.Context, entry *ldap.Entry, attributes []string, profile *directoryProfile) interface{} {
+				// A lock time of 000001010000Z means the account is locked permanently, which is not a valid timestamp
+				if entry.GetEqualFoldAttributeValue(attributes[0]) == PermanentlyLockedTime {
+					return nil
+				}
+				return timestampValue(ctx, entry, attributes, profile)
+			},
+		},
+		{
+			Name:        "pwd_policy_subentry",
+			Description: "Distinguished name of the ppolicy password policy that applies to the user. Empty if the default policy applies.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  fixedAttributes("pwdPolicySubentry"),
+		},
+
+		// Other Columns
+		{
+			Name:        "description",
+			Description: "Description of the user.",
+			Type:        proto.ColumnType_STRING,
+			Sort:        plugin.SortAll,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "base_dn",
+			Description: "The Base DN on which the search was performed.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "scope",
+			Description: "The scope of the search, one of base, one or sub. Defaults to sub, i.e. the whole subtree of the base DN.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromQual("scope").Transform(defaultSearchScope),
+			Operators:   equalsOperators,
+		},
+		{
+			Name:        "filter",
+			Description: "Optional search filter.",
+			Type:        proto.ColumnType_STRING,
+			Operators:   equalsOperators,
+		},
+
+		// JSON Columns
+		{
+			Name:        "member_of",
+			Description: "Groups that the user is a member of.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "direct_reports",
+			Description: "Distinguished names of the users whose manager is the user. Only maintained by Active Directory, as the back link of manager.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "pwd_failure_time",
+			Description: "Dates of the consecutive failed authentication attempts of the user, as maintained by the ppolicy overlay.",
+			Type:        proto.ColumnType_JSON,
+			Attributes:  fixedAttributes("pwdFailureTime"),
+			Value:       timestampsValue,
+		},
+		{
+			Name:        "object_class",
+			Description: "Object classes of the user.",
+			Type:        proto.ColumnType_JSON,
+			Value:       valuesValue,
+		},
+		{
+			Name:        "attributes",
+			Description: "All attributes that have been returned from LDAP.",
+			Type:        proto.ColumnType_JSON,
+			Value:       attributesValue,
+		},
+
+		// Steampipe Columns
+		{
+			Name:        "title",
+			Description: "Title of the user.",
+			Type:        proto.ColumnType_STRING,
+			Attributes:  columnAttributes("cn"),
+		},
+	},
 }
 
 func tableLDAPUser(ctx context.Context) *plugin.Table {
@@ -65,153 +285,14 @@ func tableLDAPUser(ctx context.Context) *plugin.Table {
 		Name:        "ldap_user",
 		Description: "A user is known as the customer or end-user.",
 		Get: &plugin.GetConfig{
-			KeyColumns: plugin.SingleColumn("dn"),
+			KeyColumns: userTable.getKeyColumns(),
 			Hydrate:    getUser,
 		},
 		List: &plugin.ListConfig{
-			Hydrate: listUsers,
-			KeyColumns: []*plugin.KeyColumn{
-				{Name: "cn", Require: plugin.Optional},
-				{Name: "department", Require: plugin.Optional},
-				{Name: "description", Require: plugin.Optional},
-				{Name: "disabled", Operators: []string{"<>", "="}, Require: plugin.Optional},
-				{Name: "display_name", Require: plugin.Optional},
-				{Name: "filter", Require: plugin.Optional},
-				{Name: "given_name", Require: plugin.Optional},
-				{Name: "mail", Require: plugin.Optional},
-				{Name: "object_sid", Require: plugin.Optional},
-				{Name: "sam_account_name", Require: plugin.Optional},
-				{Name: "surname", Require: plugin.Optional},
-				{Name: "user_principal_name", Require: plugin.Optional},
-				{Name: "when_changed", Operators: []string{">", ">=", "=", "<", "<="}, Require: plugin.Optional},
-				{Name: "when_created", Operators: []string{">", ">=", "=", "<", "<="}, Require: plugin.Optional},
-			},
+			Hydrate:    listUsers,
+			KeyColumns: userTable.keyColumns(),
 		},
-		Columns: commonColumns([]*plugin.Column{
-			// Top Columns
-			{
-				Name:        "dn",
-				Description: "Distinguished name of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "cn",
-				Description: "Full name of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "display_name",
-				Description: "Display name of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "object_sid",
-				Description: "The security identifier (SID) of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "given_name",
-				Description: "Given name of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "surname",
-				Description: "Family name of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "initials",
-				Description: "Initials of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "mail",
-				Description: "E-mail address of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "department",
-				Description: "Department to which the user belongs to.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "when_created",
-				Description: "Date when the user was created.",
-				Type:        proto.ColumnType_TIMESTAMP,
-			},
-			{
-				Name:        "when_changed",
-				Description: "Date when the user was last changed.",
-				Type:        proto.ColumnType_TIMESTAMP,
-			},
-			{
-				Name:        "sam_account_name",
-				Description: "Logon name (pre-Windows 2000) of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "user_principal_name",
-				Description: "Login name of the user, usually mapped to the user email name.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "job_title",
-				Description: "Job title of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "ou",
-				Description: "Organizational unit to which the user belongs to.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "disabled",
-				Description: "Whether the user account is disabled.",
-				Type:        proto.ColumnType_BOOL,
-			},
-
-			// Other Columns
-			{
-				Name:        "description",
-				Description: "Description of the user.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "base_dn",
-				Description: "The Base DN on which the search was performed.",
-				Type:        proto.ColumnType_STRING,
-			},
-			{
-				Name:        "filter",
-				Description: "Optional search filter.",
-				Type:        proto.ColumnType_STRING,
-			},
-
-			// JSON Columns
-			{
-				Name:        "member_of",
-				Description: "Groups that the user is a member of.",
-				Type:        proto.ColumnType_JSON,
-			},
-			{
-				Name:        "object_class",
-				Description: "Object classes of the user.",
-				Type:        proto.ColumnType_JSON,
-			},
-			{
-				Name:        "attributes",
-				Description: "All attributes that have been returned from LDAP.",
-				Type:        proto.ColumnType_JSON,
-			},
-
-			// Steampipe Columns
-			{
-				Name:        "title",
-				Description: "Title of the user.",
-				Type:        proto.ColumnType_STRING,
-				Transform:   transform.FromField("Cn"),
-			},
-		}),
+		Columns: userTable.columns(),
 	}
 }
 
@@ -219,173 +300,49 @@ func getUser(ctx context.Context, d *plugin.QueryData, h *plugin.HydrateData) (i
 	logger := plugin.Logger(ctx)
 	logger.Trace("ldap_user.getUser")
 
-	userDN := d.EqualsQuals["dn"].GetStringValue()
-
-	ldapConfig := GetConfig(d.Connection)
-
-	var searchReq *ldap.SearchRequest
-
-	if ldapConfig.Attributes != nil {
-		searchReq = ldap.NewSearchRequest(userDN, ldap.ScopeBaseObject, 0, 1, 0, false, "(&)", ldapConfig.Attributes, []ldap.Control{})
-	} else {
-		searchReq = ldap.NewSearchRequest(userDN, ldap.ScopeBaseObject, 0, 1, 0, false, "(&)", []string{}, []ldap.Control{})
+	profile, err := userTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_user.getUser", "profile_error", err)
+		return nil, err
 	}
 
-	result, err := search(ctx, d, searchReq)
+	row, err := userTable.getRow(ctx, d, profile)
 	if err != nil {
 		logger.Error("ldap_user.getUser", "search_error", err)
 		return nil, err
 	}
 
-	if len(result.Entries) > 0 {
-		entry := result.Entries[0]
-		row := userRow{
-			Dn:                entry.DN,
-			BaseDn:            *ldapConfig.BaseDN,
-			Cn:                entry.GetAttributeValue("cn"),
-			Description:       entry.GetAttributeValue("description"),
-			DisplayName:       entry.GetAttributeValue("displayName"),
-			GivenName:         entry.GetAttributeValue("givenName"),
-			Initials:          entry.GetAttributeValue("initials"),
-			Mail:              entry.GetAttributeValue("mail"),
-			ObjectClass:       entry.GetAttributeValues("objectClass"),
-			Ou:                getOrganizationUnit(entry.DN),
-			Surname:           entry.GetAttributeValue("sn"),
-			JobTitle:          entry.GetAttributeValue("title"),
-			Department:        entry.GetAttributeValue("department"),
-			ObjectSid:         getObjectSid(entry),
-			SamAccountName:    entry.GetAttributeValue("sAMAccountName"),
-			UserPrincipalName: entry.GetAttributeValue("userPrincipalName"),
-			MemberOf:          entry.GetAttributeValues("memberOf"),
-			Attributes:        transformAttributes(ctx, entry.Attributes),
-			Disabled:          verifyUserDisabled(ctx, entry),
-		}
-
-		// Populate Time fields
-		if !time.Time.IsZero(*convertToTimestamp(ctx, entry.GetAttributeValue("whenCreated"))) {
-			row.WhenCreated = convertToTimestamp(ctx, entry.GetAttributeValue("whenCreated"))
-		}
-		if !time.Time.IsZero(*convertToTimestamp(ctx, entry.GetAttributeValue("whenChanged"))) {
-			row.WhenChanged = convertToTimestamp(ctx, entry.GetAttributeValue("whenChanged"))
-		}
-
-		return row, nil
-	}
-
-	return nil, nil
+	return row, nil
 }
 
 func listUsers(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
 	logger := plugin.Logger(ctx)
 	logger.Trace("ldap_user.listUsers")
 
-	var baseDN, userObjectFilter string
-	var attributes []string
-	var pageSize uint32 = PageSize
-
 	ldapConfig := GetConfig(d.Connection)
+	baseDNs := ldapConfig.baseDNs(d.Table.Name)
 
-	if ldapConfig.BaseDN != nil {
-		baseDN = *ldapConfig.BaseDN
-	}
-	if ldapConfig.Attributes != nil {
-		attributes = ldapConfig.Attributes
-	}
-	if ldapConfig.UserObjectFilter != nil {
-		userObjectFilter = *ldapConfig.UserObjectFilter
+	profile, err := userTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_user.listUsers", "profile_error", err)
+		return nil, err
 	}
 
-	keyQuals := d.EqualsQuals
-
-	// default value for the user object filter if nothing is passed
-	if userObjectFilter == "" {
-		userObjectFilter = "(&(objectCategory=person)(objectClass=user))"
-	}
+	filter := generateFilterString(d, userTable.ObjectFilter(ldapConfig, profile), profile)
 
-	filter := generateFilterString(d, userObjectFilter)
+	// If no attributes are passed in, search request will get those needed by the columns of the query
+	attributes := userTable.requestAttributes(d, profile)
 
-	logger.Debug("ldap_user.listUsers", "baseDN", baseDN)
+	logger.Debug("ldap_user.listUsers", "baseDNs", baseDNs)
 	logger.Debug("ldap_user.listUsers", "filter", filter)
 	logger.Debug("ldap_user.listUsers", "attributes", attributes)
 
-	if d.QueryContext.Limit != nil {
-		if uint32(*d.QueryContext.Limit) < pageSize {
-			pageSize = uint32(*d.QueryContext.Limit)
-		}
-	}
-
-	var searchReq *ldap.SearchRequest
-	paging := ldap.NewControlPaging(pageSize)
-
-	for {
-		// If no attributes are passed in, search request will get all of them
-		if attributes != nil {
-			searchReq = ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, 0, 0, 0, false, filter, attributes, []ldap.Control{paging})
-		} else {
-			searchReq = ldap.NewSearchRequest(baseDN, ldap.ScopeWholeSubtree, 0, 0, 0, false, filter, []string{}, []ldap.Control{paging})
-		}
-
-		result, err := search(ctx, d, searchReq)
-		if err != nil {
-			plugin.Logger(ctx).Error("ldap_user.listUsers", "search_error", err)
-			return nil, err
-		}
-
-		for _, entry := range result.Entries {
-			row := userRow{
-				Dn:                entry.DN,
-				BaseDn:            baseDN,
-				Cn:                entry.GetAttributeValue("cn"),
-				Description:       entry.GetAttributeValue("description"),
-				DisplayName:       entry.GetAttributeValue("displayName"),
-				GivenName:         entry.GetAttributeValue("givenName"),
-				Initials:          entry.GetAttributeValue("initials"),
-				Mail:              entry.GetAttributeValue("mail"),
-				ObjectClass:       entry.GetAttributeValues("objectClass"),
-				Ou:                getOrganizationUnit(entry.DN),
-				Surname:           entry.GetAttributeValue("sn"),
-				JobTitle:          entry.GetAttributeValue("title"),
-				Department:        entry.GetAttributeValue("department"),
-				ObjectSid:         getObjectSid(entry),
-				SamAccountName:    entry.GetAttributeValue("sAMAccountName"),
-				UserPrincipalName: entry.GetAttributeValue("userPrincipalName"),
-				MemberOf:          entry.GetAttributeValues("memberOf"),
-				Attributes:        transformAttributes(ctx, entry.Attributes),
-				Disabled:          verifyUserDisabled(ctx, entry),
-			}
-
-			if keyQuals["filter"] != nil {
-				row.Filter = keyQuals["filter"].GetStringValue()
-			}
-
-			// Populate Time fields
-			if !time.Time.IsZero(*convertToTimestamp(ctx, entry.GetAttributeValue("whenCreated"))) {
-				row.WhenCreated = convertToTimestamp(ctx, entry.GetAttributeValue("whenCreated"))
-			}
-			if !time.Time.IsZero(*convertToTimestamp(ctx, entry.GetAttributeValue("whenChanged"))) {
-				row.WhenChanged = convertToTimestamp(ctx, entry.GetAttributeValue("whenChanged"))
-			}
-
-			d.StreamListItem(ctx, row)
-
-			// Check if context has been cancelled or if the limit has been hit (if specified)
-			if d.RowsRemaining(ctx) == 0 {
-				return nil, nil
-			}
-		}
-
-		// If the result control does not have paging or if the paging control does not
-		// have a next page cookie exit from the loop
-		resultCtrl := ldap.FindControl(result.Controls, paging.GetControlType())
-		if resultCtrl == nil {
-			break
-		}
-		if pagingCtrl, ok := resultCtrl.(*ldap.ControlPaging); ok {
-			if len(pagingCtrl.Cookie) == 0 {
-				break
-			}
-			paging.SetCookie(pagingCtrl.Cookie)
-		}
+	err = searchBaseDNs(ctx, d, baseDNs, filter, attributes, profile, func(entry *ldap.Entry, baseDN string) {
+		d.StreamListItem(ctx, userTable.buildRow(ctx, d, entry, baseDN, profile))
+	})
+	if err != nil {
+		logger.Error("ldap_user.listUsers", "search_error", err)
+		return nil, err
 	}
 
 	return nil, nil
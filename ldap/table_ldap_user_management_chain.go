@@ -0,0 +1,239 @@
+package ldap
+
+import (
+	"context"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/transform"
+)
+
+type managementChainRow struct {
+	// Distinguished name of the user
+	Dn string
+	// Number of levels between the user and the manager, 1 for the direct manager
+	Level int
+	// Distinguished name of the manager
+	ManagerDn string
+	// Common name of the manager
+	ManagerCn string
+	// Whether the manager has no manager
+	IsTopManager bool
+	// Whether the manager is already in the chain, which ends there
+	IsCycle bool
+}
+
+// A managerLookup returns the entry of a DN with its manager and common name, or nil if there is no such entry
+type managerLookup func(dn string) (*ldap.Entry, error)
+
+func tableLDAPUserManagementChain(ctx context.Context) *plugin.Table {
+	return &plugin.Table{
+		Name:        "ldap_user_management_chain",
+		Description: "The managers of each user, from the direct manager up to the top of the organization.",
+		List: &plugin.ListConfig{
+			Hydrate: listUserManagementChains,
+			KeyColumns: []*plugin.KeyColumn{
+				{Name: "dn", Require: plugin.Optional},
+			},
+		},
+		Columns: commonColumns([]*plugin.Column{
+			// Top Columns
+			{
+				Name:        "dn",
+				Description: "Distinguished name of the user.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "level",
+				Description: "Number of levels between the user and the manager, 1 for the direct manager of the user.",
+				Type:        proto.ColumnType_INT,
+			},
+			{
+				Name:        "manager_dn",
+				Description: "Distinguished name of the manager.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "manager_cn",
+				Description: "Common name of the manager. Empty if the manager does not exist.",
+				Type:        proto.ColumnType_STRING,
+			},
+			{
+				Name:        "is_top_manager",
+				Description: "Whether the manager has no manager, i.e. is the last one of the chain.",
+				Type:        proto.ColumnType_BOOL,
+			},
+			{
+				Name:        "is_cycle",
+				Description: "Whether the manager already appears lower in the chain, or is the user, in which case the chain ends there.",
+				Type:        proto.ColumnType_BOOL,
+			},
+
+			// Steampipe Columns
+			{
+				Name:        "title",
+				Description: "Title of the manager.",
+				Type:        proto.ColumnType_STRING,
+				Transform:   transform.FromField("ManagerDn"),
+			},
+		}),
+	}
+}
+
+// listUserManagementChains follows the manager attribute of the users of the dn quals with a base object search
+// per manager. Without dn quals, all users are read at once, and the chain of each user having a manager is built
+// from them, so that only managers which are not users, or are beyond the base DNs, are searched for
+func listUserManagementChains(ctx context.Context, d *plugin.QueryData, _ *plugin.HydrateData) (interface{}, error) {
+	logger := plugin.Logger(ctx)
+	logger.Trace("ldap_user_management_chain.listUserManagementChains")
+
+	ldapConfig := GetConfig(d.Connection)
+
+	profile, err := userTable.getProfile(ctx, d)
+	if err != nil {
+		logger.Error("ldap_user_management_chain.listUserManagementChains", "profile_error", err)
+		return nil, err
+	}
+
+	managerAttribute := profile.attributeName("ldap_user", "manager_dn")
+	attributes := []string{managerAttribute, profile.attributeName("ldap_user", "cn")}
+
+	filter := userTable.ObjectFilter(ldapConfig, profile)
+
+	searchEntry := func(dn string, filter string) (*ldap.Entry, error) {
+		searchReq := ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, 0, 1, 0, false, filter, attributes, []ldap.Control{})
+		result, err := search(ctx, d, searchReq)
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Entries) == 0 {
+			return nil, nil
+		}
+		return result.Entries[0], nil
+	}
+
+	// Managers may be any entry, e.g. a contact
+	searchManager := func(dn string) (*ldap.Entry, error) {
+		return searchEntry(dn, "(objectClass=*)")
+	}
+
+	if qual := d.EqualsQuals["dn"]; qual != nil {
+		userDNs := []string{qual.GetStringValue()}
+		if qual.GetListValue() != nil {
+			userDNs = []string{}
+			for _, value := range qual.GetListValue().Values {
+				userDNs = append(userDNs, value.GetStringValue())
+			}
+		}
+
+		for _, userDN := range userDNs {
+			// The DN of the qual must be a user, unlike its managers
+			lookupUser := func(dn string) (*ldap.Entry, error) {
+				if normalizeDN(dn) == normalizeDN(userDN) {
+					return searchEntry(dn, filter)
+				}
+				return searchManager(dn)
+			}
+			if err := streamManagementChain(ctx, d, userDN, managerAttribute, profile, lookupUser); err != nil {
+				logger.Error("ldap_user_management_chain.listUserManagementChains", "search_error", err)
+				return nil, err
+			}
+			// Context can be cancelled due to manual cancellation or the limit has been hit
+			if d.RowsRemaining(ctx) == 0 {
+				return nil, nil
+			}
+		}
+
+		return nil, nil
+	}
+
+	// The users in the order they were read, and the entries read so far keyed by normalized DN
+	var users []*ldap.Entry
+	entries := map[string]*ldap.Entry{}
+
+	// The chains are built once all the users have been read, so the users are not read in pages of the limit
+	err = scanBaseDNs(ctx, d, ldapConfig.baseDNs("ldap_user"), filter, attributes, func(entry *ldap.Entry, _ string) {
+		users = append(users, entry)
+		entries[normalizeDN(entry.DN)] = entry
+	})
+	if err != nil {
+		logger.Error("ldap_user_management_chain.listUserManagementChains", "search_error", err)
+		return nil, err
+	}
+
+	lookupManager := func(dn string) (*ldap.Entry, error) {
+		if entry, ok := entries[normalizeDN(dn)]; ok {
+			return entry, nil
+		}
+		entry, err := searchManager(dn)
+		if err != nil {
+			return nil, err
+		}
+		entries[normalizeDN(dn)] = entry
+		return entry, nil
+	}
+
+	for _, user := range users {
+		if user.GetEqualFoldAttributeValue(managerAttribute) == "" {
+			continue
+		}
+		if err := streamManagementChain(ctx, d, user.DN, managerAttribute, profile, lookupManager); err != nil {
+			logger.Error("ldap_user_management_chain.listUserManagementChains", "search_error", err)
+			return nil, err
+		}
+		if d.RowsRemaining(ctx) == 0 {
+			return nil, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// streamManagementChain streams a row per manager of a user, following the manager attribute until an entry has
+// no manager, the manager does not exist, or the manager is already in the chain
+func streamManagementChain(ctx context.Context, d *plugin.QueryData, userDN string, managerAttribute string, profile *directoryProfile, lookup managerLookup) error {
+	user, err := lookup(userDN)
+	if err != nil || user == nil {
+		return err
+	}
+
+	// DNs of the chain so far, to detect management cycles, e.g. two users managing each other
+	chain := map[string]bool{normalizeDN(user.DN): true}
+	managerDN := user.GetEqualFoldAttributeValue(managerAttribute)
+
+	for level := 1; managerDN != ""; level++ {
+		row := managementChainRow{
+			Dn:        user.DN,
+			Level:     level,
+			ManagerDn: managerDN,
+		}
+
+		if chain[normalizeDN(managerDN)] {
+			row.IsCycle = true
+			d.StreamListItem(ctx, row)
+			return nil
+		}
+		chain[normalizeDN(managerDN)] = true
+
+		manager, err := lookup(managerDN)
+		if err != nil {
+			return err
+		}
+
+		managerDN = ""
+		if manager != nil {
+			row.ManagerCn = profile.attributeValue("ldap_user", "cn", manager)
+			managerDN = manager.GetEqualFoldAttributeValue(managerAttribute)
+		}
+		row.IsTopManager = managerDN == ""
+
+		d.StreamListItem(ctx, row)
+
+		if d.RowsRemaining(ctx) == 0 {
+			return nil
+		}
+	}
+
+	return nil
+}
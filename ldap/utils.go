@@ -3,14 +3,17 @@ package ldap
 import (
 	"context"
 	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/bwmarrin/go-objectsid"
 	"github.com/go-ldap/ldap/v3"
-	"github.com/iancoleman/strcase"
 	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
 	"github.com/turbot/steampipe-plugin-sdk/v5/memoize"
 	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
@@ -20,31 +23,79 @@ import (
 // Map containing column name to ldap display name mapping for properties having different column name and ldap display name.
 // https://docs.microsoft.com/en-us/windows/win32/adschema/attributes-all
 var ldapDisplayNames = map[string]string{
-	"surname": "sn",
+	"direct_reports": "directReports",
+	"job_title":      "title",
+	"manager_dn":     "manager",
+	"surname":        "sn",
+	"usn_changed":    "uSNChanged",
+	"usn_created":    "uSNCreated",
 }
 
-// Define the constant page size to be used by all ldap tables
+// Operational attributes holding the creation and modification time of every entry (RFC 4512).
+// They are not returned when all attributes are requested, so they must be requested explicitly
+var timestampOperationalAttributes = []string{"createTimestamp", "modifyTimestamp"}
+
+// Map containing the values of the scope column to LDAP search scopes
+var searchScopes = map[string]int{
+	"base": ldap.ScopeBaseObject,
+	"one":  ldap.ScopeSingleLevel,
+	"sub":  ldap.ScopeWholeSubtree,
+}
+
+// Ports on which Active Directory domain controllers serve the Global Catalog
+const (
+	GlobalCatalogPort    = "3268"
+	GlobalCatalogTLSPort = "3269"
+)
+
+// Define the default page size to be used by all ldap tables, unless set in the connection config
 const PageSize uint32 = 1000
 
 // Define the time filter timestamp format
 const FilterTimestampFormat = "20060102150405.000Z"
 
+// pwdAccountLockedTime value used by the ppolicy overlay for accounts locked until an administrator unlocks them
+const PermanentlyLockedTime = "000001010000Z"
+
 // Disabled User Filter
 const DisabledUserFilter = "(userAccountControl:1.2.840.113556.1.4.803:=2)"
 
-func connect(_ context.Context, d *plugin.QueryData) (*ldap.Conn, error) {
+// Attributes of the root DSE used to identify the directory server and its capabilities
+var rootDSEAttributes = []string{"objectClass", "vendorName", "vendorVersion", "namingContexts", "defaultNamingContext", "supportedCapabilities", "supportedControl", "supportedLDAPVersion", "ipaTopologyPluginVersion", "configurationNamingContext", "rootDomainNamingContext"}
+
+// connectHost connects to the given host, e.g. a domain controller of another domain of the forest,
+// with the credentials of the connection config. An empty host connects to the configured host
+func connectHost(_ context.Context, d *plugin.QueryData, host string) (*ldap.Conn, error) {
 
 	// Load connection from cache
-	cacheKey := "ldap"
+	cacheKey := connectionCacheKey(host)
 	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
 		return cachedData.(*ldap.Conn), nil
 	}
 
-	var username, password, host, port, baseDN string
+	ldapConfig := GetConfig(d.Connection)
+	if host != "" {
+		ldapConfig.Host = &host
+	}
+
+	ldapConn, err := dial(ldapConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save to cache
+	// TODO: Use SetWithTTL once we know what default timeout is
+	d.ConnectionManager.Cache.Set(cacheKey, ldapConn)
+
+	return ldapConn, nil
+}
+
+// dial opens a new connection to the server of the connection config and binds with its credentials
+func dial(ldapConfig ldapConfig) (*ldap.Conn, error) {
+	var username, password, host, port string
 	tlsRequired := false
 	tlsInsecureSkipVerify := true
 
-	ldapConfig := GetConfig(d.Connection)
 	if ldapConfig.Username != nil {
 		username = *ldapConfig.Username
 	}
@@ -60,10 +111,6 @@ func connect(_ context.Context, d *plugin.QueryData) (*ldap.Conn, error) {
 	if ldapConfig.TLSRequired != nil {
 		tlsRequired = *ldapConfig.TLSRequired
 	}
-	if ldapConfig.BaseDN != nil {
-		baseDN = *ldapConfig.BaseDN
-	}
-
 	// Check for all required config args
 	if username == "" {
 		return nil, errors.New("'username' must be set in the connection configuration. Edit your connection configuration file and then restart Steampipe")
@@ -74,11 +121,18 @@ func connect(_ context.Context, d *plugin.QueryData) (*ldap.Conn, error) {
 	if host == "" {
 		return nil, errors.New("'host' must be set in the connection configuration. Edit your connection configuration file and then restart Steampipe")
 	}
+	// Default to the Global Catalog ports of Active Directory
+	if port == "" && ldapConfig.GlobalCatalog != nil && *ldapConfig.GlobalCatalog {
+		port = GlobalCatalogPort
+		if tlsRequired {
+			port = GlobalCatalogTLSPort
+		}
+	}
 	if port == "" {
 		return nil, errors.New("'port' must be set in the connection configuration. Edit your connection configuration file and then restart Steampipe")
 	}
-	if baseDN == "" {
-		return nil, errors.New("'base_dn' must be set in the connection configuration. Edit your connection configuration file and then restart Steampipe")
+	if len(ldapConfig.baseDNs("")) == 0 {
+		return nil, errors.New("'base_dn' or 'base_dns' must be set in the connection configuration. Edit your connection configuration file and then restart Steampipe")
 	}
 
 	var ldapConn *ldap.Conn
@@ -97,19 +151,23 @@ func connect(_ context.Context, d *plugin.QueryData) (*ldap.Conn, error) {
 	}
 
 	if err := ldapConn.Bind(username, password); err != nil {
+		ldapConn.Close()
 		return nil, err
 	}
 
-	// Save to cache
-	// TODO: Use SetWithTTL once we know what default timeout is
-	d.ConnectionManager.Cache.Set(cacheKey, ldapConn)
-
 	return ldapConn, nil
 }
 
-func reconnect(ctx context.Context, d *plugin.QueryData) (*ldap.Conn, error) {
-	d.ConnectionManager.Cache.Delete("ldap")
-	conn, err := connect(ctx, d)
+func connectionCacheKey(host string) string {
+	if host == "" {
+		return "ldap"
+	}
+	return "ldap_" + strings.ToLower(host)
+}
+
+func reconnect(ctx context.Context, d *plugin.QueryData, host string) (*ldap.Conn, error) {
+	d.ConnectionManager.Cache.Delete(connectionCacheKey(host))
+	conn, err := connectHost(ctx, d, host)
 	if err != nil {
 		plugin.Logger(ctx).Error("ldap_utils.reconnect", "reconnect_error", err)
 		return nil, err
@@ -117,8 +175,35 @@ func reconnect(ctx context.Context, d *plugin.QueryData) (*ldap.Conn, error) {
 	return conn, nil
 }
 
+// search runs a search request on the server holding its base DN, i.e. the configured host,
+// or in forest mode a domain controller of the domain the base DN belongs to
 func search(ctx context.Context, d *plugin.QueryData, searchReq *ldap.SearchRequest) (*ldap.SearchResult, error) {
-	conn, err := connect(ctx, d)
+	host, err := hostForDN(ctx, d, searchReq.BaseDN)
+	if err != nil {
+		return nil, err
+	}
+
+	searchResult, err := searchHost(ctx, d, host, searchReq)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultReferral) {
+		return nil, err
+	}
+
+	// The server may return continuation references along with entries, or refer the whole search to another server
+	referrals := append(searchResult.Referrals, errorReferrals(err)...)
+	if len(referrals) == 0 {
+		return searchResult, nil
+	}
+	err = followReferrals(ctx, d, searchReq, referrals, func(entry *ldap.Entry) {
+		searchResult.Entries = append(searchResult.Entries, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return searchResult, nil
+}
+
+func searchHost(ctx context.Context, d *plugin.QueryData, host string, searchReq *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	conn, err := connectHost(ctx, d, host)
 	if err != nil {
 		plugin.Logger(ctx).Error("ldap_utils.search", "connection_error", err)
 		return nil, err
@@ -126,25 +211,529 @@ func search(ctx context.Context, d *plugin.QueryData, searchReq *ldap.SearchRequ
 	searchResult, e := conn.Search(searchReq)
 	if e != nil && ldap.IsErrorWithCode(e, 200) {
 		plugin.Logger(ctx).Info("LDAP Connection closed, trying to reconnect...")
-		conn, err := reconnect(ctx, d)
+		conn, err := reconnect(ctx, d, host)
 		if err != nil {
 			return nil, err
 		}
-		searchResult, err := conn.Search(searchReq)
+		searchResult, e = conn.Search(searchReq)
+	}
+	return checkSearchResult(searchResult, e)
+}
+
+// checkSearchResult returns the error of a search, except when the base DN doesn't exist,
+// which is reported as an empty result, e.g. when getting an object by a DN that doesn't exist
+func checkSearchResult(searchResult *ldap.SearchResult, err error) (*ldap.SearchResult, error) {
+	if err == nil {
+		return searchResult, nil
+	}
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return &ldap.SearchResult{}, nil
+	}
+	// Referrals are followed by the caller
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultReferral) {
+		if searchResult == nil {
+			searchResult = &ldap.SearchResult{}
+		}
+		return searchResult, err
+	}
+	return nil, err
+}
+
+// searchStream runs a search request like search, but passes entries to handleEntry as they arrive instead of
+// buffering them, and stops reading the response once no more rows are needed. The result holds the controls
+// and continuation references of the response, but no entries
+func searchStream(ctx context.Context, d *plugin.QueryData, searchReq *ldap.SearchRequest, handleEntry func(entry *ldap.Entry)) (*ldap.SearchResult, error) {
+	host, err := hostForDN(ctx, d, searchReq.BaseDN)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := connectHost(ctx, d, host)
+	if err != nil {
+		plugin.Logger(ctx).Error("ldap_utils.searchStream", "connection_error", err)
+		return nil, err
+	}
+
+	searchResult, streamed, err := streamResponse(ctx, d, conn, searchReq, handleEntry)
+	// The search can only be run again if none of its entries have been handled yet
+	if err != nil && ldap.IsErrorWithCode(err, 200) && streamed == 0 {
+		plugin.Logger(ctx).Info("LDAP Connection closed, trying to reconnect...")
+		conn, err := reconnect(ctx, d, host)
 		if err != nil {
 			return nil, err
 		}
+		searchResult, _, err = streamResponse(ctx, d, conn, searchReq, handleEntry)
+	}
+
+	searchResult, err = checkSearchResult(searchResult, err)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultReferral) {
+		return nil, err
+	}
+
+	referrals := append(searchResult.Referrals, errorReferrals(err)...)
+	if len(referrals) == 0 || d.RowsRemaining(ctx) == 0 {
 		return searchResult, nil
 	}
+
+	if err := followReferrals(ctx, d, searchReq, referrals, handleEntry); err != nil {
+		return nil, err
+	}
+
 	return searchResult, nil
 }
 
-func generateFilterString(d *plugin.QueryData, objectFilter string) string {
+// streamResponse reads the response of an asynchronous search on conn, passing entries to handleEntry,
+// and returns the number of entries handled
+func streamResponse(ctx context.Context, d *plugin.QueryData, conn *ldap.Conn, searchReq *ldap.SearchRequest, handleEntry func(entry *ldap.Entry)) (*ldap.SearchResult, int, error) {
+	// The server keeps a paged search open until its last page has been read. When no more rows are needed,
+	// the rest of the page is read anyway, even once the query has been cancelled, to get the cookie
+	// the search is abandoned with
+	paged := ldap.FindControl(searchReq.Controls, ldap.ControlTypePaging) != nil
+	parentCtx := ctx
+	if paged {
+		parentCtx = context.WithoutCancel(ctx)
+	}
+
+	// Cancelling the search stops reading its response once enough rows have been streamed
+	searchCtx, cancel := context.WithCancel(parentCtx)
+	defer cancel()
+
+	response := conn.SearchAsync(searchCtx, searchReq, 0)
+	result := &ldap.SearchResult{}
+	streamed := 0
+	stopped := false
+
+	for response.Next() {
+		switch {
+		case response.Entry() != nil:
+			if stopped {
+				continue
+			}
+			handleEntry(response.Entry())
+			streamed++
+
+			// Check if context has been cancelled or if the limit has been hit (if specified)
+			if d.RowsRemaining(ctx) == 0 {
+				if paged {
+					stopped = true
+					continue
+				}
+				cancel()
+				// Drain the response, so that the goroutine reading it notices the cancellation and exits
+				go func() {
+					for response.Next() {
+					}
+				}()
+				return result, streamed, nil
+			}
+		case response.Referral() != "":
+			if !stopped {
+				result.Referrals = append(result.Referrals, response.Referral())
+			}
+		default:
+			result.Controls = append(result.Controls, response.Controls()...)
+		}
+	}
+
+	// The rows needed have been streamed, so an error reading the rest of the page doesn't fail the query
+	if stopped {
+		if err := response.Err(); err != nil {
+			plugin.Logger(ctx).Warn("ldap_utils.streamResponse", "page_error", err)
+		}
+		return result, streamed, nil
+	}
+
+	return result, streamed, response.Err()
+}
+
+// searchPaged runs a search under baseDN using the simple paged results control (RFC 2696) with pages of pageSize
+// entries, along with the given controls, and streams every entry returned to handleEntry, until all pages have been
+// read or no more rows are needed
+func searchPaged(ctx context.Context, d *plugin.QueryData, baseDN string, scope int, filter string, attributes []string, controls []ldap.Control, pageSize uint32, handleEntry func(entry *ldap.Entry)) error {
+	searchReq := ldap.NewSearchRequest(baseDN, scope, 0, 0, 0, false, filter, attributes, controls)
+	_, err := pagedSearch(ctx, d, searchReq, pageSize, func(pageReq *ldap.SearchRequest, handlePageEntry func(entry *ldap.Entry)) (*ldap.SearchResult, error) {
+		return searchStream(ctx, d, pageReq, handlePageEntry)
+	}, handleEntry)
+	return err
+}
+
+// pagedSearch runs a search page by page using the simple paged results control along with the controls of the request,
+// starting with pages of pageSize entries. Each page is requested with searchPage, which streams its entries, and the entries not handled yet are passed to handleEntry.
+// When the server refuses a page because it exceeds its limits, the page is requested again with half the size.
+// The continuation references returned by the pages are returned
+func pagedSearch(ctx context.Context, d *plugin.QueryData, searchReq *ldap.SearchRequest, pageSize uint32, searchPage func(pageReq *ldap.SearchRequest, handlePageEntry func(entry *ldap.Entry)) (*ldap.SearchResult, error), handleEntry func(entry *ldap.Entry)) ([]string, error) {
+	paging := ldap.NewControlPaging(pageSize)
+	var referrals []string
+
+	// Number of entries from the current position of the search which have already been handled,
+	// as a page refused by the server may have been partially streamed before being requested again
+	handled := 0
+
+	for {
+		pageReq := *searchReq
+		pageReq.Controls = append([]ldap.Control{paging}, searchReq.Controls...)
+
+		position := 0
+		result, err := searchPage(&pageReq, func(entry *ldap.Entry) {
+			position++
+			if position > handled {
+				handled++
+				handleEntry(entry)
+			}
+		})
+		if err != nil {
+			if isLimitExceeded(err) && paging.PagingSize > 1 {
+				paging.PagingSize /= 2
+				plugin.Logger(ctx).Warn("ldap_utils.pagedSearch", "limit_exceeded", err, "page_size", paging.PagingSize)
+				continue
+			}
+			return nil, err
+		}
+		handled -= position
+		referrals = append(referrals, result.Referrals...)
+
+		// If the result has no paging control or if the paging control does not
+		// have a next page cookie, the last page has been read
+		cookie := pagingCookie(result.Controls)
+
+		// Check if context has been cancelled or if the limit has been hit (if specified)
+		if d.RowsRemaining(ctx) == 0 {
+			if len(cookie) > 0 {
+				abandonPagedSearch(ctx, searchReq, cookie, searchPage)
+			}
+			return referrals, nil
+		}
+
+		if len(cookie) == 0 {
+			break
+		}
+		paging.SetCookie(cookie)
+	}
+
+	return referrals, nil
+}
+
+// pagingCookie returns the cookie of the paged results control of a page, which is empty after the last page
+func pagingCookie(controls []ldap.Control) []byte {
+	if pagingCtrl, ok := ldap.FindControl(controls, ldap.ControlTypePaging).(*ldap.ControlPaging); ok {
+		return pagingCtrl.Cookie
+	}
+	return nil
+}
+
+// abandonPagedSearch releases the resources the server holds for a paged search which is not read to the end,
+// by requesting a page of size 0 with the cookie of the last page (RFC 2696)
+func abandonPagedSearch(ctx context.Context, searchReq *ldap.SearchRequest, cookie []byte, searchPage func(pageReq *ldap.SearchRequest, handlePageEntry func(entry *ldap.Entry)) (*ldap.SearchResult, error)) {
+	paging := ldap.NewControlPaging(0)
+	paging.SetCookie(cookie)
+
+	abandonReq := *searchReq
+	abandonReq.Controls = append([]ldap.Control{paging}, searchReq.Controls...)
+
+	if _, err := searchPage(&abandonReq, func(*ldap.Entry) {}); err != nil {
+		plugin.Logger(ctx).Warn("ldap_utils.abandonPagedSearch", "abandon_error", err)
+	}
+}
+
+// configuredPageSize returns the number of entries to request per page for the table of the query
+func configuredPageSize(d *plugin.QueryData) (uint32, error) {
+	return GetConfig(d.Connection).pageSize(d.Table.Name)
+}
+
+// queryPageSize returns the number of entries to request per page for a search whose entries are streamed as rows
+// one for one, which is lowered to the limit of the query if any. Searches reading more entries than the rows they
+// stream, e.g. to sort them or to walk a tree, use the configured page size instead
+func queryPageSize(d *plugin.QueryData) (uint32, error) {
+	pageSize, err := configuredPageSize(d)
+	if err != nil {
+		return 0, err
+	}
+
+	if d.QueryContext.Limit != nil {
+		if uint32(*d.QueryContext.Limit) < pageSize {
+			pageSize = uint32(*d.QueryContext.Limit)
+		}
+	}
+
+	return pageSize, nil
+}
+
+// isLimitExceeded returns whether a search failed because it exceeded a limit of the server, such as the maximum
+// page size, which requesting smaller pages may avoid
+func isLimitExceeded(err error) bool {
+	return ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) || ldap.IsErrorWithCode(err, ldap.LDAPResultAdminLimitExceeded)
+}
+
+// searchBaseDNs runs searchPaged under each of the base DNs and calls handleEntry with every entry and the base DN
+// it was found under. Entries found under several base DNs, e.g. when a base DN is nested in another, are only handled once.
+// The ORDER BY of the query is pushed down as a server side sort control (RFC 2891) when there is a single base DN
+// and the server supports it, along with the virtual list view control instead of paging if pagination is set to vlv.
+// Otherwise the entries are sorted once all of them have been read
+func searchBaseDNs(ctx context.Context, d *plugin.QueryData, baseDNs []string, filter string, attributes []string, profile *directoryProfile, handleEntry func(entry *ldap.Entry, baseDN string)) error {
+	pageSize, err := queryPageSize(d)
+	if err != nil {
+		return err
+	}
+	return searchBases(ctx, d, baseDNs, filter, attributes, profile, querySortKeys(d, profile), pageSize, handleEntry)
+}
+
+// scanBaseDNs runs searchPaged under each of the base DNs like searchBaseDNs, for searches whose entries are not
+// streamed as rows one for one, e.g. when rows are built from several entries. The entries are read in pages of
+// the configured size, in the order the server returns them
+func scanBaseDNs(ctx context.Context, d *plugin.QueryData, baseDNs []string, filter string, attributes []string, handleEntry func(entry *ldap.Entry, baseDN string)) error {
+	pageSize, err := configuredPageSize(d)
+	if err != nil {
+		return err
+	}
+	return searchBases(ctx, d, baseDNs, filter, attributes, nil, nil, pageSize, handleEntry)
+}
+
+// searchBases runs the searches of searchBaseDNs, sorting the entries by the sort keys if any
+func searchBases(ctx context.Context, d *plugin.QueryData, baseDNs []string, filter string, attributes []string, profile *directoryProfile, sortKeys []sortKey, pageSize uint32, handleEntry func(entry *ldap.Entry, baseDN string)) error {
+	logger := plugin.Logger(ctx)
+
+	baseDNs, scope, err := querySearchBases(ctx, d, baseDNs)
+	if err != nil {
+		return err
+	}
+
+	if len(sortKeys) > 0 && len(baseDNs) == 1 && supportsServerSideSort(ctx, d) {
+		logger.Debug("ldap_utils.searchBaseDNs", "server_side_sort", sortKeys)
+
+		vlv, err := isVLVPagination(ctx, d)
+		if err != nil {
+			return err
+		}
+
+		sortControl := &controlServerSideSort{SortKeys: sortKeys}
+		handleBaseDNEntry := func(entry *ldap.Entry) {
+			handleEntry(entry, baseDNs[0])
+		}
+		if vlv {
+			err = searchVLV(ctx, d, baseDNs[0], scope, filter, attributes, sortControl, pageSize, handleBaseDNEntry)
+		} else {
+			err = searchPaged(ctx, d, baseDNs[0], scope, filter, attributes, []ldap.Control{sortControl}, pageSize, handleBaseDNEntry)
+		}
+		// Servers refuse to sort on some attributes, and Active Directory only sorts on a single key
+		if !ldap.IsErrorWithCode(err, ldap.LDAPResultUnavailableCriticalExtension) && !ldap.IsErrorWithCode(err, ldap.LDAPResultVirtualListViewErrorOrControlError) {
+			return err
+		}
+		logger.Warn("ldap_utils.searchBaseDNs", "server_side_sort_error", err, "sort_keys", sortKeys)
+	}
+
+	// Entries sorted client side are all read before the first row is streamed, so they are read in full pages
+	if len(sortKeys) > 0 {
+		pageSize, err = configuredPageSize(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	seen := map[string]bool{}
+
+	// Entries to sort, along with the base DN each was found under
+	var entries []*ldap.Entry
+	entryBaseDNs := map[*ldap.Entry]string{}
+
+	for _, baseDN := range baseDNs {
+		err := searchPaged(ctx, d, baseDN, scope, filter, attributes, nil, pageSize, func(entry *ldap.Entry) {
+			if len(baseDNs) > 1 {
+				key := normalizeDN(entry.DN)
+				if seen[key] {
+					return
+				}
+				seen[key] = true
+			}
+			if len(sortKeys) > 0 {
+				entries = append(entries, entry)
+				entryBaseDNs[entry] = baseDN
+				return
+			}
+			handleEntry(entry, baseDN)
+		})
+		if err != nil {
+			return err
+		}
+
+		// Context can be cancelled due to manual cancellation or the limit has been hit
+		if d.RowsRemaining(ctx) == 0 {
+			return nil
+		}
+	}
+
+	if len(sortKeys) == 0 {
+		return nil
+	}
+
+	logger.Debug("ldap_utils.searchBaseDNs", "client_side_sort", sortKeys, "entries", len(entries))
+
+	sortEntries(ctx, d, profile, sortKeys, entries)
+
+	for _, entry := range entries {
+		handleEntry(entry, entryBaseDNs[entry])
+
+		if d.RowsRemaining(ctx) == 0 {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// querySearchBases returns the base DNs and scope of a search, i.e. the base_dn and scope quals when set,
+// or else the configured base DNs and the whole subtree scope. In forest mode, the naming contexts of all the
+// domains of the forest replace base_dn and base_dns. A parent_dn qual is a one-level search under each of its DNs
+func querySearchBases(ctx context.Context, d *plugin.QueryData, baseDNs []string) ([]string, int, error) {
+	ldapConfig := GetConfig(d.Connection)
+	if isForestMode(d) && len(ldapConfig.TableBaseDNs[d.Table.Name]) == 0 {
+		forestDNs, err := forestBaseDNs(ctx, d)
+		if err != nil {
+			return nil, 0, err
+		}
+		baseDNs = forestDNs
+	}
+
+	if qual := d.EqualsQuals["base_dn"]; qual != nil {
+		if qual.GetListValue() != nil {
+			baseDNs = []string{}
+			for _, value := range qual.GetListValue().Values {
+				baseDNs = append(baseDNs, value.GetStringValue())
+			}
+		} else {
+			baseDNs = []string{qual.GetStringValue()}
+		}
+	}
+
+	scope := ldap.ScopeWholeSubtree
+	if qual := d.EqualsQuals["scope"]; qual != nil {
+		value, ok := searchScopes[qual.GetStringValue()]
+		if !ok {
+			return nil, 0, fmt.Errorf("invalid scope %q, must be one of base, one or sub", qual.GetStringValue())
+		}
+		scope = value
+	}
+
+	if qual := d.EqualsQuals["parent_dn"]; qual != nil {
+		if qual.GetListValue() != nil {
+			baseDNs = []string{}
+			for _, value := range qual.GetListValue().Values {
+				baseDNs = append(baseDNs, value.GetStringValue())
+			}
+		} else {
+			baseDNs = []string{qual.GetStringValue()}
+		}
+		scope = ldap.ScopeSingleLevel
+	}
+
+	return baseDNs, scope, nil
+}
+
+// defaultSearchScope returns the scope of the search when no scope qual is set
+func defaultSearchScope(_ context.Context, d *transform.TransformData) (interface{}, error) {
+	if d.Value == nil {
+		return "sub", nil
+	}
+	return d.Value, nil
+}
+
+// getRootDSE reads the root DSE of the server, i.e. the entry with an empty DN (RFC 4512)
+func getRootDSE(ctx context.Context, d *plugin.QueryData) (*ldap.Entry, error) {
+	// Load root DSE from cache
+	cacheKey := "ldap_root_dse"
+	if cachedData, ok := d.ConnectionManager.Cache.Get(cacheKey); ok {
+		return cachedData.(*ldap.Entry), nil
+	}
+
+	searchReq := ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", rootDSEAttributes, []ldap.Control{})
+
+	result, err := search(ctx, d, searchReq)
+	if err != nil {
+		plugin.Logger(ctx).Error("ldap_utils.getRootDSE", "search_error", err)
+		return nil, err
+	}
+	if result == nil || len(result.Entries) == 0 {
+		return nil, errors.New("unable to read the root DSE of the LDAP server")
+	}
+
+	d.ConnectionManager.Cache.Set(cacheKey, result.Entries[0])
+
+	return result.Entries[0], nil
+}
+
+// getHighestCommittedUSN reads the highest update sequence number committed by the server holding a DN from its
+// root DSE. Unlike the rest of the root DSE, it is not cached, as it grows with every change made on the server
+func getHighestCommittedUSN(ctx context.Context, d *plugin.QueryData, dn string) (*int64, error) {
+	host, err := hostForDN(ctx, d, dn)
+	if err != nil {
+		return nil, err
+	}
+
+	searchReq := ldap.NewSearchRequest("", ldap.ScopeBaseObject, 0, 1, 0, false, "(objectClass=*)", []string{"highestCommittedUSN"}, []ldap.Control{})
+	result, err := searchHost(ctx, d, host, searchReq)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Entries) == 0 {
+		return nil, nil
+	}
+	return convertToInt(ctx, result.Entries[0].GetEqualFoldAttributeValue("highestCommittedUSN")), nil
+}
+
+// Columns which are not backed by any attribute, as they are derived from the DN, the quals or the connection
+var nonAttributeColumns = []string{"dn", "base_dn", "scope", "filter", "host_name", "domain", "dn_components", "normalized_dn", "parent_dn", "depth"}
+
+// queryAttributes returns the attributes to request for the columns used by the query, i.e. the selected columns
+// along with the columns of its quals and ORDER BY. A column is backed by the attributes listed for it in
+// columnAttributes, which may be none, or else by the attribute the profile maps it to.
+// When the attributes column is selected, all user attributes are requested along with the operational attributes
+// of the profile and the given ones, as the column reports all the attributes returned
+func queryAttributes(d *plugin.QueryData, profile *directoryProfile, columnAttributes map[string][]string, operationalAttributes ...string) []string {
+	columns := append([]string{}, d.QueryContext.Columns...)
+	for column := range d.Quals {
+		columns = append(columns, column)
+	}
+	for _, sortColumn := range d.QueryContext.SortOrder {
+		columns = append(columns, sortColumn.Column)
+	}
+
+	var attributes []string
+	for _, column := range columns {
+		if column == "attributes" {
+			attributes = append(profile.requestAttributes(operationalAttributes...), attributes...)
+			continue
+		}
+		if containsEqualFold(nonAttributeColumns, column) {
+			continue
+		}
+
+		backing, ok := columnAttributes[column]
+		if !ok {
+			backing = []string{profile.attributeName(d.Table.Name, column)}
+		}
+		for _, attribute := range backing {
+			if !containsEqualFold(attributes, attribute) {
+				attributes = append(attributes, attribute)
+			}
+		}
+	}
+
+	// The special attribute 1.1 requests no attributes at all (RFC 4511 section 4.5.1.8), e.g. for select dn
+	if len(attributes) == 0 {
+		return []string{"1.1"}
+	}
+	return attributes
+}
+
+// generateFilterString combines the object filter with clauses for the optional quals of the query.
+// Column names are mapped to LDAP attributes using the directory profile, which may be nil for tables
+// whose columns do not depend on the directory flavor.
+func generateFilterString(d *plugin.QueryData, objectFilter string, profile *directoryProfile) string {
 	var andClauses strings.Builder
 
 	// If filter is provided, ignore other optional quals
 
 	keyQuals := d.EqualsQuals
+	table := d.Table.Name
 
 	if keyQuals["filter"] != nil {
 		val := keyQuals["filter"].GetStringValue()
@@ -158,63 +747,73 @@ func generateFilterString(d *plugin.QueryData, objectFilter string) string {
 	} else {
 		// Range over the key quals
 		for key, value := range keyQuals {
-			// Skip filter since it's handled separately
-			if key == "filter" {
+			// Skip filter since it's handled separately, and the search base and scope which are not attributes
+			if key == "filter" || key == "base_dn" || key == "scope" || key == "parent_dn" {
 				continue
 			}
 			var clause string
-			if ldapDisplayNames[key] != "" {
-				key = ldapDisplayNames[key]
-			}
-			if value.GetStringValue() != "" {
-				clause = buildClause(key, value.GetStringValue(), "=")
+			attribute := profile.attributeName(table, key)
+			if qualValueString(value) != "" {
+				clause = buildClause(attribute, filterValue(attribute, qualValueString(value)), "=")
 			} else if value.GetListValue() != nil {
-				clause = generateOrClause(key, value.GetListValue())
+				clause = generateOrClause(attribute, value.GetListValue())
 			}
 			andClauses.WriteString(clause)
 		}
 		quals := d.Quals
 		// Get individual quals
-		if quals["when_created"] != nil {
-			for _, q := range quals["when_created"].Quals {
+		for _, column := range d.Table.Columns {
+			if column.Type != proto.ColumnType_TIMESTAMP || quals[column.Name] == nil {
+				continue
+			}
+			attribute := profile.attributeName(table, column.Name)
+			for _, q := range quals[column.Name].Quals {
 				var clause string
 				timeString := q.Value.GetTimestampValue().AsTime().Format(FilterTimestampFormat)
 				// LDAP filters don't support < or >, so use <= and >= instead
 				switch q.Operator {
 				case "=", ">=", "<=":
-					clause = buildClause("whenCreated", timeString, q.Operator)
+					clause = buildClause(attribute, timeString, q.Operator)
 				case ">":
-					clause = buildClause("whenCreated", timeString, ">=")
+					clause = buildClause(attribute, timeString, ">=")
 				case "<":
-					clause = buildClause("whenCreated", timeString, "<=")
+					clause = buildClause(attribute, timeString, "<=")
 				}
 				andClauses.WriteString(clause)
 			}
 		}
-
-		if quals["when_changed"] != nil {
-			for _, q := range quals["when_changed"].Quals {
+		// Range quals on integer columns, e.g. usn_changed > 12345. Their = quals are among the key quals
+		for _, column := range d.Table.Columns {
+			if column.Type != proto.ColumnType_INT || quals[column.Name] == nil {
+				continue
+			}
+			attribute := profile.attributeName(table, column.Name)
+			for _, q := range quals[column.Name].Quals {
+				value := q.Value.GetInt64Value()
 				var clause string
-				timeString := q.Value.GetTimestampValue().AsTime().Format(FilterTimestampFormat)
-				// LDAP filters don't support < or >, so use <= and >= instead
 				switch q.Operator {
-				case "=", ">=", "<=":
-					clause = buildClause("whenChanged", timeString, q.Operator)
+				case ">=", "<=":
+					clause = buildClause(attribute, strconv.FormatInt(value, 10), q.Operator)
 				case ">":
-					clause = buildClause("whenChanged", timeString, ">")
+					clause = buildClause(attribute, strconv.FormatInt(value+1, 10), ">=")
 				case "<":
-					clause = buildClause("whenChanged", timeString, "<")
+					clause = buildClause(attribute, strconv.FormatInt(value-1, 10), "<=")
 				}
 				andClauses.WriteString(clause)
 			}
 		}
 
 		if quals["disabled"] != nil {
+			disabledFilter := DisabledUserFilter
+			if profile != nil {
+				disabledFilter = profile.DisabledUserFilter
+			}
 			for _, q := range quals["disabled"].Quals {
 				value := q.Value
 				if value != nil {
-					clause := DisabledUserFilter
-					if q.Operator == "<>" {
+					clause := disabledFilter
+					// Negate the filter for "disabled <> true" and "disabled = false"
+					if (q.Operator == "<>") == value.GetBoolValue() {
 						clause = "(!" + clause + ")"
 					}
 					andClauses.WriteString(clause)
@@ -230,23 +829,22 @@ func generateOrClause(key string, orValues *proto.QualValueList) string {
 	var clauses strings.Builder
 
 	for _, value := range orValues.Values {
-		clauses.WriteString(buildClause(key, value.GetStringValue(), "="))
+		clauses.WriteString(buildClause(key, filterValue(key, qualValueString(value)), "="))
 	}
 
 	return "(|" + clauses.String() + ")"
 }
 
-func buildClause(key string, value string, operator string) string {
-	return "(" + strcase.ToLowerCamel(key) + operator + value + ")"
+// Integer quals, e.g. uid_number, are matched using their string representation
+func qualValueString(value *proto.QualValue) string {
+	if _, ok := value.GetValue().(*proto.QualValue_Int64Value); ok {
+		return strconv.FormatInt(value.GetInt64Value(), 10)
+	}
+	return value.GetStringValue()
 }
 
-func getOrganizationUnit(dn string) string {
-	index := strings.Index(strings.ToUpper(dn), "OU")
-	if index > -1 {
-		return dn[index:]
-	} else {
-		return ""
-	}
+func buildClause(attribute string, value string, operator string) string {
+	return "(" + attribute + operator + value + ")"
 }
 
 func getObjectSid(entry *ldap.Entry) string {
@@ -257,16 +855,43 @@ func getObjectSid(entry *ldap.Entry) string {
 	return ""
 }
 
+// getObjectGUID returns the string representation of the binary objectGUID of an AD entry, whose first three
+// fields are stored in little-endian order, e.g. 8a3f4ec6-4a77-4e0b-9b3a-2f1d0c3e5a71
+func getObjectGUID(entry *ldap.Entry) string {
+	raw := entry.GetRawAttributeValue("objectGUID")
+	if len(raw) != 16 {
+		return ""
+	}
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(raw[0:4]), binary.LittleEndian.Uint16(raw[4:6]), binary.LittleEndian.Uint16(raw[6:8]), raw[8:10], raw[10:16])
+}
+
+// objectGUIDFilterValue returns the escaped binary form of a GUID, as AD only matches objectGUID against its binary value,
+// and whether the value is a GUID at all
+func objectGUIDFilterValue(guid string) (string, bool) {
+	raw, err := hex.DecodeString(strings.ReplaceAll(guid, "-", ""))
+	if err != nil || len(raw) != 16 {
+		return "", false
+	}
+	// Swap the first three fields to little-endian order
+	ordered := append([]byte{raw[3], raw[2], raw[1], raw[0], raw[5], raw[4], raw[7], raw[6]}, raw[8:]...)
+
+	var value strings.Builder
+	for _, b := range ordered {
+		fmt.Fprintf(&value, "\\%02x", b)
+	}
+	return value.String(), true
+}
+
 func convertToTimestamp(ctx context.Context, str string) *time.Time {
 	// If there is a blank string, return zero time
 	if str == "" {
 		return &time.Time{}
 	}
 
-	// Frame the layout according to the data available. The front part remains constant to '20060102150405'
-	// The second part i.e. after '.' can have a variable number of 0's followed by Z
-	layout := "20060102150405." + strings.Split(str, ".")[1]
-	t, err := time.Parse(layout, str)
+	// Generalized time, e.g. '20210830112105.0Z' (Active Directory), '20210830112105Z' (OpenLDAP) or
+	// '20210830112105.123456Z' (OpenLDAP accesslog). The fractional seconds, if any, are parsed as well
+	// although the layout omits them
+	t, err := time.Parse("20060102150405Z", str)
 	if err != nil {
 		plugin.Logger(ctx).Error("ldap_utils.convertToTimestamp", "conversion_error", err)
 		// Return zero time in case of a conversion error
@@ -276,6 +901,65 @@ func convertToTimestamp(ctx context.Context, str string) *time.Time {
 	return &t
 }
 
+func convertToTimestamps(ctx context.Context, values []string) []*time.Time {
+	var timestamps []*time.Time
+	for _, value := range values {
+		if t := convertToTimestamp(ctx, value); !t.IsZero() {
+			timestamps = append(timestamps, t)
+		}
+	}
+	return timestamps
+}
+
+// Shadow attributes such as shadowLastChange and shadowExpire are stored as a number of days since 1970-01-01
+func convertDaysToTimestamp(ctx context.Context, str string) *time.Time {
+	days := convertToInt(ctx, str)
+	if days == nil || *days < 0 {
+		return nil
+	}
+	t := time.Unix(*days*24*60*60, 0).UTC()
+	return &t
+}
+
+func convertToInt(ctx context.Context, str string) *int64 {
+	if str == "" {
+		return nil
+	}
+	i, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		plugin.Logger(ctx).Error("ldap_utils.convertToInt", "conversion_error", err)
+		return nil
+	}
+	return &i
+}
+
+func convertToBool(ctx context.Context, str string) *bool {
+	if str == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		plugin.Logger(ctx).Error("ldap_utils.convertToBool", "conversion_error", err)
+		return nil
+	}
+	return &b
+}
+
+// AD stores durations such as maxPwdAge and lockoutDuration as negative intervals of 100 nanoseconds.
+// The smallest int64 value is used to represent "never", in which case nil is returned.
+// Refer - https://docs.microsoft.com/en-us/windows/win32/adschema/a-maxpwdage
+func convertIntervalToSeconds(ctx context.Context, str string) *int64 {
+	interval := convertToInt(ctx, str)
+	if interval == nil || *interval == math.MinInt64 {
+		return nil
+	}
+	seconds := *interval / -10000000
+	if seconds < 0 {
+		seconds = -seconds
+	}
+	return &seconds
+}
+
 func transformAttributes(ctx context.Context, attributes []*ldap.EntryAttribute) map[string][]string {
 	var data = make(map[string][]string)
 	for _, attribute := range attributes {
@@ -293,6 +977,36 @@ func commonColumns(c []*plugin.Column) []*plugin.Column {
 			Hydrate:     getHostName,
 			Transform:   transform.FromValue(),
 		},
+		{
+			Name:        "domain",
+			Description: "The DNS name of the domain the object belongs to, derived from the domain components of its distinguished name.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromField("Dn", "dn").Transform(transformDomain),
+		},
+		{
+			Name:        "dn_components",
+			Description: "The attribute types and values of the relative distinguished names making up the distinguished name of the object, from the object up to the root.",
+			Type:        proto.ColumnType_JSON,
+			Transform:   transform.FromField("Dn", "dn").Transform(transformDNComponents),
+		},
+		{
+			Name:        "normalized_dn",
+			Description: "Distinguished name of the object in lowercase, with uniform spacing and escaping, to compare and join distinguished names written differently.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromField("Dn", "dn").Transform(transformNormalizedDN),
+		},
+		{
+			Name:        "parent_dn",
+			Description: "Distinguished name of the container of the object. Empty for a naming context made of a single relative distinguished name.",
+			Type:        proto.ColumnType_STRING,
+			Transform:   transform.FromField("Dn", "dn").Transform(transformParentDN),
+		},
+		{
+			Name:        "depth",
+			Description: "Number of relative distinguished names making up the distinguished name of the object, e.g. 2 for DC=example,DC=com.",
+			Type:        proto.ColumnType_INT,
+			Transform:   transform.FromField("Dn", "dn").Transform(transformDepth),
+		},
 	}, c...)
 }
 
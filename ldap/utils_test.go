@@ -0,0 +1,122 @@
+package ldap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/hashicorp/go-hclog"
+	"github.com/turbot/steampipe-plugin-sdk/v5/grpc/proto"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/context_key"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin/quals"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGenerateFilterString(t *testing.T) {
+	table := &plugin.Table{
+		Name: "ldap_user",
+		Columns: []*plugin.Column{
+			{Name: "manager_dn", Type: proto.ColumnType_STRING},
+			{Name: "object_guid", Type: proto.ColumnType_STRING},
+			{Name: "usn_changed", Type: proto.ColumnType_INT},
+			{Name: "when_changed", Type: proto.ColumnType_TIMESTAMP},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		equalsQuals plugin.KeyColumnEqualsQualMap
+		quals       plugin.KeyColumnQualMap
+		profile     *directoryProfile
+		want        string
+	}{
+		{
+			name:        "dn with an escaped comma",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"manager_dn": stringQualValue(`CN=Doe\, John,OU=Staff,DC=example,DC=com`)},
+			want:        `(&(objectClass=user)(manager=CN=Doe\5c, John,OU=Staff,DC=example,DC=com))`,
+		},
+		{
+			name:        "dn with parentheses and an asterisk",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"manager_dn": stringQualValue(`CN=Smith (Bob*),DC=example,DC=com`)},
+			want:        `(&(objectClass=user)(manager=CN=Smith \28Bob\2a\29,DC=example,DC=com))`,
+		},
+		{
+			name: "list of values",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"manager_dn": {Value: &proto.QualValue_ListValue{ListValue: &proto.QualValueList{Values: []*proto.QualValue{
+				stringQualValue("CN=a*,DC=example,DC=com"),
+				stringQualValue("CN=b,DC=example,DC=com"),
+			}}}}},
+			want: `(&(objectClass=user)(|(manager=CN=a\2a,DC=example,DC=com)(manager=CN=b,DC=example,DC=com)))`,
+		},
+		{
+			name:        "binary objectGUID is not escaped again",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"object_guid": stringQualValue("c64e3f8a-774a-0b4e-9b3a-2f1d0c3e5a71")},
+			profile:     directoryProfiles[DirectoryTypeActiveDirectory],
+			want:        `(&(objectClass=user)(objectGUID=\8a\3f\4e\c6\4a\77\4e\0b\9b\3a\2f\1d\0c\3e\5a\71))`,
+		},
+		{
+			name:        "objectGUID which is not a GUID",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"object_guid": stringQualValue("*")},
+			profile:     directoryProfiles[DirectoryTypeActiveDirectory],
+			want:        `(&(objectClass=user)(objectGUID=\2a))`,
+		},
+		{
+			name:  "int range",
+			quals: plugin.KeyColumnQualMap{"usn_changed": qualList("usn_changed", &proto.QualValue{Value: &proto.QualValue_Int64Value{Int64Value: 1000}}, ">", "<=")},
+			want:  `(&(objectClass=user)(uSNChanged>=1001)(uSNChanged<=1000))`,
+		},
+		{
+			name:  "int lower than",
+			quals: plugin.KeyColumnQualMap{"usn_changed": qualList("usn_changed", &proto.QualValue{Value: &proto.QualValue_Int64Value{Int64Value: 1000}}, "<")},
+			want:  `(&(objectClass=user)(uSNChanged<=999))`,
+		},
+		{
+			name:  "timestamp range",
+			quals: plugin.KeyColumnQualMap{"when_changed": qualList("when_changed", &proto.QualValue{Value: &proto.QualValue_TimestampValue{TimestampValue: timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))}}, ">", "<=")},
+			want:  `(&(objectClass=user)(whenChanged>=20240102030405.000Z)(whenChanged<=20240102030405.000Z))`,
+		},
+		{
+			name:        "filter qual is used as is",
+			equalsQuals: plugin.KeyColumnEqualsQualMap{"filter": stringQualValue("cn=a*")},
+			want:        `(&(objectClass=user)(cn=a*))`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quals := test.quals
+			if quals == nil {
+				quals = plugin.KeyColumnQualMap{}
+			}
+			d := &plugin.QueryData{Table: table, EqualsQuals: test.equalsQuals, Quals: quals}
+
+			got := generateFilterString(d, "(objectClass=user)", test.profile)
+			if got != test.want {
+				t.Errorf("generateFilterString() = %s, want %s", got, test.want)
+			}
+			if _, err := ldap.CompileFilter(got); err != nil {
+				t.Errorf("generateFilterString() = %s, which does not compile: %v", got, err)
+			}
+		})
+	}
+}
+
+func stringQualValue(value string) *proto.QualValue {
+	return &proto.QualValue{Value: &proto.QualValue_StringValue{StringValue: value}}
+}
+
+// qualList returns the quals of a column comparing it to a value with each of the operators
+func qualList(column string, value *proto.QualValue, operators ...string) *plugin.KeyColumnQuals {
+	columnQuals := &plugin.KeyColumnQuals{Name: column}
+	for _, operator := range operators {
+		columnQuals.Quals = append(columnQuals.Quals, &quals.Qual{Column: column, Operator: operator, Value: value})
+	}
+	return columnQuals
+}
+
+// testContext returns a context holding the logger plugin.Logger expects
+func testContext() context.Context {
+	return context.WithValue(context.Background(), context_key.Logger, hclog.NewNullLogger())
+}
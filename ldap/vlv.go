@@ -0,0 +1,184 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/turbot/steampipe-plugin-sdk/v5/plugin"
+)
+
+// Supported values for the pagination connection config argument
+const (
+	PaginationPaged = "paged"
+	PaginationVLV   = "vlv"
+)
+
+// controlVLV is the virtual list view request control (draft-ietf-ldapext-ldapv3-vlv), which selects a window
+// of a sorted result by offset. It must be sent along with a server side sort control
+type controlVLV struct {
+	// Number of entries to return before and after the target entry
+	BeforeCount int64
+	AfterCount  int64
+	// Position of the target entry, starting at 1, and the number of entries the client assumes the result holds
+	Offset       int64
+	ContentCount int64
+	// Context returned by the server with the previous window, if any
+	ContextID []byte
+}
+
+func (c *controlVLV) GetControlType() string {
+	return ldap.ControlTypeVLVRequest
+}
+
+func (c *controlVLV) Encode() *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Control")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, c.GetControlType(), "Control Type"))
+	packet.AppendChild(ber.NewBoolean(ber.ClassUniversal, ber.TypePrimitive, ber.TagBoolean, true, "Criticality"))
+
+	value := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "Control Value")
+	request := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "VirtualListViewRequest")
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.BeforeCount, "beforeCount"))
+	request.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.AfterCount, "afterCount"))
+	byOffset := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "byOffset")
+	byOffset.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.Offset, "offset"))
+	byOffset.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, c.ContentCount, "contentCount"))
+	request.AppendChild(byOffset)
+	if len(c.ContextID) > 0 {
+		contextID := ber.Encode(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, nil, "contextID")
+		contextID.Data.Write(c.ContextID)
+		request.AppendChild(contextID)
+	}
+	value.AppendChild(request)
+	packet.AppendChild(value)
+
+	return packet
+}
+
+func (c *controlVLV) String() string {
+	return fmt.Sprintf("Control Type: %s (%q)  Criticality: true  Offset: %d  ContentCount: %d  AfterCount: %d", ldap.ControlTypeMap[c.GetControlType()], c.GetControlType(), c.Offset, c.ContentCount, c.AfterCount)
+}
+
+// A vlvResponse is the virtual list view response control returned along with a window
+type vlvResponse struct {
+	// Position of the target entry and number of entries of the result, as estimated by the server
+	TargetPosition int64
+	ContentCount   int64
+	// Result code of the virtual list view operation
+	Result    int64
+	ContextID []byte
+}
+
+// findVLVResponse decodes the virtual list view response control of a search result, if any.
+// go-ldap returns controls it doesn't know about with their raw value
+func findVLVResponse(controls []ldap.Control) (*vlvResponse, error) {
+	control, ok := ldap.FindControl(controls, ldap.ControlTypeVLVResponse).(*ldap.ControlString)
+	if !ok {
+		return nil, nil
+	}
+
+	packet, err := ber.DecodePacketErr([]byte(control.ControlValue))
+	if err != nil {
+		return nil, err
+	}
+	if len(packet.Children) < 3 {
+		return nil, errors.New("invalid virtual list view response control")
+	}
+
+	response := &vlvResponse{}
+	for i, field := range []*int64{&response.TargetPosition, &response.ContentCount, &response.Result} {
+		value, err := ber.ParseInt64(packet.Children[i].Data.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		*field = value
+	}
+	if len(packet.Children) > 3 {
+		response.ContextID = packet.Children[3].Data.Bytes()
+	}
+
+	return response, nil
+}
+
+// isVLVPagination returns whether sorted searches should be paginated with the virtual list view control, i.e. when
+// pagination is set to vlv in the connection config and the root DSE advertises the control
+func isVLVPagination(ctx context.Context, d *plugin.QueryData) (bool, error) {
+	ldapConfig := GetConfig(d.Connection)
+	if ldapConfig.Pagination == nil || *ldapConfig.Pagination == PaginationPaged {
+		return false, nil
+	}
+	if *ldapConfig.Pagination != PaginationVLV {
+		return false, fmt.Errorf("'pagination' must be one of %q or %q, got %q. Edit your connection configuration file and then restart Steampipe", PaginationPaged, PaginationVLV, *ldapConfig.Pagination)
+	}
+
+	rootDSE, err := getRootDSE(ctx, d)
+	if err != nil {
+		return false, err
+	}
+	if !containsEqualFold(rootDSE.GetEqualFoldAttributeValues("supportedControl"), ldap.ControlTypeVLVRequest) {
+		plugin.Logger(ctx).Debug("ldap_vlv.isVLVPagination", "unsupported_control", ldap.ControlTypeVLVRequest)
+		return false, nil
+	}
+	return true, nil
+}
+
+// searchVLV runs a sorted search under baseDN, reading the result from its first entry in windows of the page size
+// with the virtual list view control, and streams every entry returned to handleEntry, until the end of the result
+// has been reached or no more rows are needed. Like pages, windows are shrunk when the server refuses them
+func searchVLV(ctx context.Context, d *plugin.QueryData, baseDN string, scope int, filter string, attributes []string, sortControl ldap.Control, pageSize uint32, handleEntry func(entry *ldap.Entry)) error {
+	vlv := &controlVLV{
+		AfterCount: int64(pageSize) - 1,
+		Offset:     1,
+	}
+
+	for {
+		searchReq := ldap.NewSearchRequest(baseDN, scope, 0, 0, 0, false, filter, attributes, []ldap.Control{sortControl, vlv})
+
+		streamed := 0
+		result, err := searchStream(ctx, d, searchReq, func(entry *ldap.Entry) {
+			streamed++
+			handleEntry(entry)
+		})
+		if err != nil {
+			if isLimitExceeded(err) && vlv.AfterCount > 0 {
+				// Entries of the refused window may have been streamed already
+				vlv.Offset += int64(streamed)
+				vlv.AfterCount /= 2
+				plugin.Logger(ctx).Warn("ldap_vlv.searchVLV", "limit_exceeded", err, "window_size", vlv.AfterCount+1)
+				continue
+			}
+			return err
+		}
+
+		// Check if context has been cancelled or if the limit has been hit (if specified)
+		if d.RowsRemaining(ctx) == 0 {
+			return nil
+		}
+
+		response, err := findVLVResponse(result.Controls)
+		if err != nil {
+			return err
+		}
+		if response == nil {
+			return errors.New("the server did not return a virtual list view response control")
+		}
+		if response.Result != ldap.LDAPResultSuccess {
+			return ldap.NewError(uint16(response.Result), errors.New("virtual list view failed"))
+		}
+
+		plugin.Logger(ctx).Debug("ldap_vlv.searchVLV", "offset", vlv.Offset, "entries", streamed, "content_count", response.ContentCount)
+
+		// Move the window past the entries returned, keeping the offset relative to the size of the result
+		// reported by the server, until the end of the result
+		vlv.Offset += int64(streamed)
+		vlv.ContentCount = response.ContentCount
+		vlv.ContextID = response.ContextID
+		if streamed == 0 || vlv.Offset > response.ContentCount {
+			break
+		}
+	}
+
+	return nil
+}